@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+)
+
+// /grumpy is registered here rather than in permission, which the command
+// package itself depends on.
+func init() {
+	capabilityOptions := []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "capability",
+			Description: "what the role may do",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "create", Value: string(permission.Create)},
+				{Name: "delete own", Value: string(permission.DeleteOwn)},
+				{Name: "delete any", Value: string(permission.DeleteAny)},
+				{Name: "admin", Value: string(permission.Admin)},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionRole,
+			Name:        "role",
+			Description: "role",
+			Required:    true,
+		},
+	}
+
+	command.Register(&command.Command{
+		Name:        "grumpy",
+		Description: "manage the bot",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+				Name:        "permissions",
+				Description: "which roles may do what",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "view",
+						Description: "show which roles have each capability",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "grant",
+						Description: "give a role a capability",
+						Options:     capabilityOptions,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "revoke",
+						Description: "take a capability from a role",
+						Options:     capabilityOptions,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "reload",
+				Description: "read the config file again (bot owner only)",
+			},
+		},
+		Capability: permission.Admin,
+		Handler:    grumpy,
+	})
+}
+
+func grumpy(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	group, action, _ := strings.Cut(args.Subcommand, " ")
+
+	switch group {
+	case "permissions":
+		permission.Permissions(s, i, action, permission.Capability(args.String("capability")), args.Role("role"))
+	case "reload":
+		reloadCommand(s, i, args)
+	default:
+		command.Fail(s, i, "Use /grumpy permissions or /grumpy reload.")
+	}
+}
+
+// reloadCommand is SIGHUP for the bot's owner. The configuration is shared
+// by every guild, so being an admin in one isn't enough. Syncing commands
+// after a reload can take longer than an interaction may go unanswered.
+func reloadCommand(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	content := reloadAs(s, i, args.User.ID)
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+}
+
+func reloadAs(s *discordgo.Session, i *discordgo.InteractionCreate, userId string) string {
+	owner, err := owns(s, userId)
+	if err != nil {
+		log.Printf("Cannot look up the bot's owner: %v", err)
+		return "I couldn't check who owns me."
+	}
+	if !owner {
+		log.Printf("audit: user %s was refused a configuration reload in guild %s", userId, i.GuildID)
+		return "Only my owner can reload the configuration."
+	}
+
+	log.Printf("audit: user %s reloaded the configuration from guild %s", userId, i.GuildID)
+
+	content, err := reload()
+	if err != nil {
+		log.Printf("Cannot reload the configuration: %v", err)
+		return fmt.Sprintf("I kept the old configuration: %s", err)
+	}
+	return content
+}
+
+// owns reports whether userId owns the bot's application, alone or as a
+// member of the team that does.
+func owns(s *discordgo.Session, userId string) (bool, error) {
+	app, err := s.Application("@me")
+	if err != nil {
+		return false, err
+	}
+
+	if app.Team != nil {
+		for _, member := range app.Team.Members {
+			if member.User != nil && member.User.ID == userId {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return app.Owner != nil && app.Owner.ID == userId, nil
+}
@@ -0,0 +1,206 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Args are the options a command was run with, checked against its
+// definition: every option is one it declares, of the declared type, and
+// every required option is there.
+type Args struct {
+	// User is who ran the command, in a server or a DM.
+	User *discordgo.User
+	// Subcommand is the subcommand that was run, after its group if it has
+	// one, like "permissions view".
+	Subcommand string
+	// Target is the id of the message or user a context menu command was
+	// run on.
+	Target string
+	// Focused is the option being autocompleted.
+	Focused string
+
+	options  map[string]*discordgo.ApplicationCommandInteractionDataOption
+	resolved *discordgo.ApplicationCommandInteractionDataResolved
+}
+
+// NewArgs builds Args for user from options without checking them against
+// a command, for tests.
+func NewArgs(user *discordgo.User, options ...*discordgo.ApplicationCommandInteractionDataOption) Args {
+	args := Args{User: user, options: make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))}
+	for _, option := range options {
+		args.options[option.Name] = option
+	}
+	return args
+}
+
+// Has reports whether the option was given.
+func (a Args) Has(name string) bool {
+	_, ok := a.options[name]
+	return ok
+}
+
+// String is a string option, or what has been typed so far into any option
+// being autocompleted. It is "" if the option wasn't given.
+func (a Args) String(name string) string {
+	option, ok := a.options[name]
+	if !ok {
+		return ""
+	}
+	value, _ := option.Value.(string)
+	return value
+}
+
+// Int is an integer option, or 0 if it wasn't given.
+func (a Args) Int(name string) int64 {
+	return int64(a.Float(name))
+}
+
+// Float is a number option, or 0 if it wasn't given.
+func (a Args) Float(name string) float64 {
+	option, ok := a.options[name]
+	if !ok {
+		return 0
+	}
+	value, _ := option.Value.(float64)
+	return value
+}
+
+// Bool is a boolean option, or false if it wasn't given.
+func (a Args) Bool(name string) bool {
+	option, ok := a.options[name]
+	if !ok {
+		return false
+	}
+	value, _ := option.Value.(bool)
+	return value
+}
+
+// Role is the id of a role option.
+func (a Args) Role(name string) string {
+	return a.String(name)
+}
+
+// Channel looks up a channel option, or returns nil if it wasn't given.
+func (a Args) Channel(s *discordgo.Session, name string) *discordgo.Channel {
+	option, ok := a.options[name]
+	if !ok {
+		return nil
+	}
+	return option.ChannelValue(s)
+}
+
+// Attachment is a file option, or nil if it wasn't given.
+func (a Args) Attachment(name string) *discordgo.MessageAttachment {
+	if a.resolved == nil {
+		return nil
+	}
+	return a.resolved.Attachments[a.String(name)]
+}
+
+// parseArgs checks data against c's options. Autocompletion is partial: the
+// command isn't finished, so required options may be missing and values
+// aren't checked.
+func parseArgs(c *Command, data discordgo.ApplicationCommandInteractionData, partial bool) (Args, error) {
+	args := Args{
+		Target:   data.TargetID,
+		options:  make(map[string]*discordgo.ApplicationCommandInteractionDataOption),
+		resolved: data.Resolved,
+	}
+
+	if err := parseOptions(c.Options, data.Options, &args, partial); err != nil {
+		return args, err
+	}
+
+	return args, nil
+}
+
+func parseOptions(definitions []*discordgo.ApplicationCommandOption, options []*discordgo.ApplicationCommandInteractionDataOption, args *Args, partial bool) error {
+	for _, option := range options {
+		if option == nil {
+			return fmt.Errorf("an option is missing")
+		}
+
+		definition := findOption(definitions, option.Name)
+		if definition == nil {
+			return fmt.Errorf("I don't know the option `%s`", option.Name)
+		}
+		if definition.Type != option.Type {
+			return fmt.Errorf("`%s` should be a %s", option.Name, strings.ToLower(definition.Type.String()))
+		}
+
+		switch option.Type {
+		case discordgo.ApplicationCommandOptionSubCommandGroup, discordgo.ApplicationCommandOptionSubCommand:
+			args.Subcommand = strings.TrimSpace(args.Subcommand + " " + option.Name)
+			return parseOptions(definition.Options, option.Options, args, partial)
+		}
+
+		if option.Focused {
+			args.Focused = option.Name
+		}
+
+		if !partial {
+			if err := checkValue(definition, option); err != nil {
+				return err
+			}
+		}
+
+		args.options[option.Name] = option
+	}
+
+	for _, definition := range definitions {
+		switch definition.Type {
+		case discordgo.ApplicationCommandOptionSubCommandGroup, discordgo.ApplicationCommandOptionSubCommand:
+			// Reaching here means none was picked.
+			return fmt.Errorf("pick a subcommand")
+		}
+
+		if definition.Required && !partial && !args.Has(definition.Name) {
+			return fmt.Errorf("`%s` is required", definition.Name)
+		}
+	}
+
+	return nil
+}
+
+func findOption(definitions []*discordgo.ApplicationCommandOption, name string) *discordgo.ApplicationCommandOption {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition
+		}
+	}
+	return nil
+}
+
+// checkValue makes sure an option's value has the Go type its type decodes
+// to, so the Args getters never see anything else, and is one of the
+// choices if it has any.
+func checkValue(definition *discordgo.ApplicationCommandOption, option *discordgo.ApplicationCommandInteractionDataOption) error {
+	var ok bool
+
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		_, ok = option.Value.(float64)
+	case discordgo.ApplicationCommandOptionBoolean:
+		_, ok = option.Value.(bool)
+	default:
+		var value string
+		value, ok = option.Value.(string)
+		if ok && len(definition.Choices) > 0 && option.Type == discordgo.ApplicationCommandOptionString {
+			ok = false
+			for _, choice := range definition.Choices {
+				if choice.Value == value {
+					ok = true
+				}
+			}
+		}
+	}
+
+	if !ok {
+		return fmt.Errorf("I can't use that value for `%s`", option.Name)
+	}
+
+	return nil
+}
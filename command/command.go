@@ -0,0 +1,143 @@
+// Package command keeps every slash command, button and modal the bot
+// handles, and routes interactions to them.
+package command
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/permission"
+)
+
+// Handler runs a command once its arguments are parsed and checked against
+// the command's options.
+type Handler func(s *discordgo.Session, i *discordgo.InteractionCreate, args Args)
+
+// Command is a slash command, or a context menu command when Type says so.
+type Command struct {
+	Name        string
+	Description string
+	Type        discordgo.ApplicationCommandType
+	Options     []*discordgo.ApplicationCommandOption
+	// Capability is what the member running the command needs to be
+	// allowed to do, or "" if anyone may run it.
+	Capability permission.Capability
+	Handler    Handler
+	// Autocomplete suggests values for the options marked Autocomplete.
+	// Required options may be missing and values are whatever has been
+	// typed so far.
+	Autocomplete Handler
+}
+
+// Interaction handles a button, select or modal. Its custom id starts with
+// the prefix it was registered under, followed by a colon and whatever the
+// handler needs.
+type Interaction func(s *discordgo.Session, i *discordgo.InteractionCreate)
+
+// custom is a button, select or modal handler along with the command it
+// belongs to.
+type custom struct {
+	command string
+	handler Interaction
+}
+
+var (
+	commands   []*Command
+	byName     = make(map[string]*Command)
+	components = make(map[string]custom)
+	modals     = make(map[string]custom)
+
+	disabledMu sync.RWMutex
+	disabled   map[string]bool
+)
+
+// Register adds commands to the registry. Call it from init. Registering a
+// name twice panics, like registering a migration out of order.
+func Register(cmds ...*Command) {
+	for _, c := range cmds {
+		if _, ok := byName[c.Name]; ok {
+			panic(fmt.Sprintf("command %q registered twice", c.Name))
+		}
+		if c.Handler == nil {
+			panic(fmt.Sprintf("command %q has no handler", c.Name))
+		}
+		commands = append(commands, c)
+		byName[c.Name] = c
+	}
+}
+
+// RegisterComponent routes buttons and selects whose custom ids start with
+// prefix to h. They belong to the command called name, registered before
+// them: they are turned off with it and need its capability.
+func RegisterComponent(name string, prefix string, h Interaction) {
+	register(components, "component", name, prefix, h)
+}
+
+// RegisterModal routes modals whose custom ids start with prefix to h. They
+// belong to the command called name like components do.
+func RegisterModal(name string, prefix string, h Interaction) {
+	register(modals, "modal", name, prefix, h)
+}
+
+func register(handlers map[string]custom, kind string, name string, prefix string, h Interaction) {
+	if strings.Contains(prefix, ":") {
+		panic(fmt.Sprintf("%s prefix %q has a colon", kind, prefix))
+	}
+	if _, ok := handlers[prefix]; ok {
+		panic(fmt.Sprintf("%s %q registered twice", kind, prefix))
+	}
+	if _, ok := byName[name]; !ok {
+		panic(fmt.Sprintf("%s %q belongs to unregistered command %q", kind, prefix, name))
+	}
+	handlers[prefix] = custom{command: name, handler: h}
+}
+
+// Definitions are the registered commands as Discord wants them, in the
+// order they were registered. Disabled commands are left out.
+func Definitions() []*discordgo.ApplicationCommand {
+	disabledMu.RLock()
+	defer disabledMu.RUnlock()
+
+	var definitions []*discordgo.ApplicationCommand
+	for _, c := range commands {
+		if disabled[c.Name] {
+			continue
+		}
+		definitions = append(definitions, &discordgo.ApplicationCommand{
+			Name:        c.Name,
+			Description: c.Description,
+			Type:        c.Type,
+			Options:     c.Options,
+		})
+	}
+	return definitions
+}
+
+// Known reports whether a command called name is registered.
+func Known(name string) bool {
+	_, ok := byName[name]
+	return ok
+}
+
+// Disable turns the named commands off and every other one back on. Sync
+// the commands afterwards so Discord stops offering them; until then Route
+// turns them away.
+func Disable(names []string) {
+	off := make(map[string]bool, len(names))
+	for _, name := range names {
+		off[name] = true
+	}
+
+	disabledMu.Lock()
+	disabled = off
+	disabledMu.Unlock()
+}
+
+func enabled(name string) bool {
+	disabledMu.RLock()
+	defer disabledMu.RUnlock()
+
+	return !disabled[name]
+}
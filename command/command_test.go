@@ -0,0 +1,204 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var testCommand = &Command{
+	Name: "test_set",
+	Options: []*discordgo.ApplicationCommandOption{
+		{Type: discordgo.ApplicationCommandOptionString, Name: "message", Required: true},
+		{Type: discordgo.ApplicationCommandOptionInteger, Name: "count"},
+		{Type: discordgo.ApplicationCommandOptionBoolean, Name: "loud"},
+		{
+			Type: discordgo.ApplicationCommandOptionString,
+			Name: "mode",
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "word", Value: "word"},
+				{Name: "regex", Value: "regex"},
+			},
+		},
+	},
+	Handler: func(s *discordgo.Session, i *discordgo.InteractionCreate, args Args) {},
+}
+
+var testGroups = &Command{
+	Name: "test_admin",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name: "permissions",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "view"},
+				{
+					Type: discordgo.ApplicationCommandOptionSubCommand,
+					Name: "grant",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Required: true},
+					},
+				},
+			},
+		},
+	},
+	Handler: func(s *discordgo.Session, i *discordgo.InteractionCreate, args Args) {},
+}
+
+func init() {
+	Register(testCommand, testGroups)
+}
+
+func option(name string, optionType discordgo.ApplicationCommandOptionType, value interface{}, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: optionType, Value: value, Options: options}
+}
+
+func interaction(data discordgo.InteractionData) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: "1",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "me"}},
+		Data:      data,
+	}}
+}
+
+func TestParseArgs(t *testing.T) {
+	args, err := parseArgs(testCommand, discordgo.ApplicationCommandInteractionData{
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			option("message", discordgo.ApplicationCommandOptionString, "hello"),
+			option("count", discordgo.ApplicationCommandOptionInteger, float64(3)),
+			option("loud", discordgo.ApplicationCommandOptionBoolean, true),
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args.String("message") != "hello" || args.Int("count") != 3 || !args.Bool("loud") {
+		t.Errorf("parsed %+v", args.options)
+	}
+	if args.Has("mode") || args.String("mode") != "" {
+		t.Error("mode wasn't given")
+	}
+
+	bad := map[string][]*discordgo.ApplicationCommandInteractionDataOption{
+		"is required": nil,
+		"don't know": {
+			option("message", discordgo.ApplicationCommandOptionString, "hello"),
+			option("color", discordgo.ApplicationCommandOptionString, "red"),
+		},
+		"should be": {
+			option("message", discordgo.ApplicationCommandOptionInteger, float64(1)),
+		},
+		"can't use that value for `count`": {
+			option("message", discordgo.ApplicationCommandOptionString, "hello"),
+			option("count", discordgo.ApplicationCommandOptionInteger, "three"),
+		},
+		"can't use that value for `mode`": {
+			option("message", discordgo.ApplicationCommandOptionString, "hello"),
+			option("mode", discordgo.ApplicationCommandOptionString, "glob"),
+		},
+	}
+
+	for want, options := range bad {
+		_, err := parseArgs(testCommand, discordgo.ApplicationCommandInteractionData{Options: options}, false)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want an error saying %q", err, want)
+		}
+	}
+}
+
+func TestParseSubcommands(t *testing.T) {
+	args, err := parseArgs(testGroups, discordgo.ApplicationCommandInteractionData{
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			option("permissions", discordgo.ApplicationCommandOptionSubCommandGroup, nil,
+				option("grant", discordgo.ApplicationCommandOptionSubCommand, nil,
+					option("role", discordgo.ApplicationCommandOptionRole, "42"))),
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Subcommand != "permissions grant" || args.Role("role") != "42" {
+		t.Errorf("got subcommand %q and role %q", args.Subcommand, args.Role("role"))
+	}
+
+	_, err = parseArgs(testGroups, discordgo.ApplicationCommandInteractionData{
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			option("permissions", discordgo.ApplicationCommandOptionSubCommandGroup, nil,
+				option("grant", discordgo.ApplicationCommandOptionSubCommand, nil)),
+		},
+	}, false)
+	if err == nil {
+		t.Error("grant without a role was accepted")
+	}
+
+	_, err = parseArgs(testGroups, discordgo.ApplicationCommandInteractionData{}, false)
+	if err == nil {
+		t.Error("no subcommand was accepted")
+	}
+}
+
+func TestParseAutocomplete(t *testing.T) {
+	count := option("count", discordgo.ApplicationCommandOptionInteger, "1")
+	count.Focused = true
+
+	args, err := parseArgs(testCommand, discordgo.ApplicationCommandInteractionData{
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{count},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Focused != "count" || args.String(args.Focused) != "1" {
+		t.Errorf("focused %q is %q", args.Focused, args.String(args.Focused))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c, args, err := resolve(interaction(discordgo.ApplicationCommandInteractionData{
+		Name: "test_set",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			option("message", discordgo.ApplicationCommandOptionString, "hello"),
+		},
+	}), false)
+	if err != nil || c != testCommand || args.User.ID != "me" {
+		t.Errorf("resolve = %v, %+v, %v", c, args, err)
+	}
+
+	malformed := []*discordgo.InteractionCreate{
+		interaction(discordgo.ApplicationCommandInteractionData{Name: "no_such_command"}),
+		interaction(discordgo.MessageComponentInteractionData{CustomID: "test_set"}),
+		interaction(nil),
+	}
+
+	nobody := interaction(discordgo.ApplicationCommandInteractionData{Name: "test_set"})
+	nobody.Member = nil
+	malformed = append(malformed, nobody)
+
+	for _, i := range malformed {
+		if _, _, err := resolve(i, false); err == nil {
+			t.Errorf("resolved %+v", i.Data)
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a command twice didn't panic")
+		}
+	}()
+
+	Register(&Command{Name: "test_set", Handler: testCommand.Handler})
+}
+
+func TestRegisterComponentNeedsCommand(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a component for an unknown command didn't panic")
+		}
+	}()
+
+	RegisterComponent("test_nothing", "test_page", func(s *discordgo.Session, i *discordgo.InteractionCreate) {})
+}
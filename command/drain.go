@@ -0,0 +1,85 @@
+package command
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// root is the context background work runs in.
+	root = context.Background()
+
+	drainMu  sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+)
+
+// SetContext makes ctx the context of the work handlers start with Go. Call
+// it before the session opens, and cancel ctx once Drain returns to stop
+// whatever is still running.
+func SetContext(ctx context.Context) {
+	root = ctx
+}
+
+// Go runs f in the background for a handler answering i, like an image
+// being built after the interaction was answered. Drain waits for it, and a
+// panic in f is recovered like one in a handler.
+func Go(s *discordgo.Session, i *discordgo.InteractionCreate, f func(ctx context.Context)) {
+	inflight.Add(1)
+	go func() {
+		defer inflight.Done()
+		defer Recover(s, i)
+
+		f(root)
+	}()
+}
+
+// Message wraps a message handler like Safe and counts it as in flight, so
+// Drain waits for it too. Messages that arrive while draining are ignored.
+func Message(h func(s *discordgo.Session, m *discordgo.MessageCreate)) func(s *discordgo.Session, m *discordgo.MessageCreate) {
+	safe := Safe(h)
+	return func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		if !begin() {
+			return
+		}
+		defer inflight.Done()
+
+		safe(s, m)
+	}
+}
+
+// Drain stops Route accepting interactions and Message accepting messages,
+// then waits for the handlers already running and the work they started with Go to finish. It gives up
+// when ctx is done, returning its error.
+func Drain(ctx context.Context) error {
+	drainMu.Lock()
+	draining = true
+	drainMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// begin counts an interaction as in flight, unless the bot is draining.
+func begin() bool {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+
+	if draining {
+		return false
+	}
+	inflight.Add(1)
+	return true
+}
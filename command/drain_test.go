@@ -0,0 +1,53 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDrain(t *testing.T) {
+	defer func() { draining = false }()
+
+	if !begin() {
+		t.Fatal("turned away before draining")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain with an interaction in flight = %v", err)
+	}
+
+	if begin() {
+		t.Error("accepted an interaction while draining")
+	}
+
+	inflight.Done()
+	if err := Drain(context.Background()); err != nil {
+		t.Errorf("Drain with nothing in flight = %v", err)
+	}
+}
+
+func TestDrainMessages(t *testing.T) {
+	defer func() { draining = false }()
+
+	ran := 0
+	h := Message(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		ran++
+	})
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{ID: "1", ChannelID: "2"}}
+
+	h(nil, m)
+	if err := Drain(context.Background()); err != nil {
+		t.Errorf("Drain after a message was handled = %v", err)
+	}
+
+	h(nil, m)
+	if ran != 1 {
+		t.Errorf("handled %d messages, want only the one before draining", ran)
+	}
+}
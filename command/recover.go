@@ -0,0 +1,75 @@
+package command
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Recover stops a panic in a handler from taking the bot down. It logs the
+// stack with a reference the member who sent i is told, so they can report
+// it. Route already recovers handlers, so defer it at the top of goroutines
+// they start.
+func Recover(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	ref := report(r, fmt.Sprintf("interaction %s in guild %s", i.ID, i.GuildID))
+
+	// Autocomplete can only be answered with choices.
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+
+	message := fmt.Sprintf("Something broke (ref: %s).", ref)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		// The handler already answered before it broke.
+		s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+	}
+}
+
+// Safe wraps a message handler so a panic in it is logged instead of taking
+// the bot down. There's nobody to answer, so it's only logged.
+func Safe(h func(s *discordgo.Session, m *discordgo.MessageCreate)) func(s *discordgo.Session, m *discordgo.MessageCreate) {
+	return func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		defer func() {
+			if r := recover(); r != nil {
+				report(r, fmt.Sprintf("message %s in guild %s channel %s", m.ID, m.GuildID, m.ChannelID))
+			}
+		}()
+
+		h(s, m)
+	}
+}
+
+// report logs a recovered panic and what was being handled with its stack,
+// under a short reference to find it by.
+func report(r interface{}, what string) string {
+	ref := reference()
+	log.Printf("panic handling %s (ref: %s): %v\n%s", what, ref, r, debug.Stack())
+	return ref
+}
+
+func reference() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSafe(t *testing.T) {
+	ran := false
+	h := Safe(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		ran = true
+		var nobody *discordgo.User
+		_ = nobody.ID
+	})
+
+	h(nil, &discordgo.MessageCreate{Message: &discordgo.Message{ID: "1", ChannelID: "2"}})
+
+	if !ran {
+		t.Error("the handler didn't run")
+	}
+}
+
+func TestReference(t *testing.T) {
+	a, b := reference(), reference()
+	if len(a) != 6 || a == b {
+		t.Errorf("references %q and %q", a, b)
+	}
+}
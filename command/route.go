@@ -0,0 +1,162 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/permission"
+)
+
+// Route hands an interaction to what was registered for it. Add it to the
+// session as its only interaction handler. Interactions nothing was
+// registered for, or that don't fit their definition, get an ephemeral
+// error instead of reaching a handler, and handlers that panic get one
+// with a reference to the logged stack. Once Drain is called it turns
+// everything away.
+func Route(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer Recover(s, i)
+
+	if !begin() {
+		Fail(s, i, "I'm shutting down, try again in a minute.")
+		return
+	}
+	defer inflight.Done()
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		routeCommand(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		routeAutocomplete(s, i)
+	case discordgo.InteractionMessageComponent:
+		data, ok := i.Data.(discordgo.MessageComponentInteractionData)
+		if !ok {
+			Fail(s, i, "I couldn't read that.")
+			return
+		}
+		routeCustomId(s, i, components, data.CustomID, "That doesn't do anything anymore.")
+	case discordgo.InteractionModalSubmit:
+		data, ok := i.Data.(discordgo.ModalSubmitInteractionData)
+		if !ok {
+			Fail(s, i, "I couldn't read that.")
+			return
+		}
+		routeCustomId(s, i, modals, data.CustomID, "That form doesn't do anything anymore.")
+	}
+}
+
+func routeCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	c, args, err := resolve(i, false)
+	if err != nil {
+		log.Printf("bad interaction %s in guild %s: %s", i.ID, i.GuildID, err)
+		Fail(s, i, fmt.Sprintf("I can't run that: %s.", err))
+		return
+	}
+
+	if !permission.Allowed(s, i, c.Capability) {
+		return
+	}
+
+	c.Handler(s, i, args)
+}
+
+func routeAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	c, args, err := resolve(i, true)
+	if err != nil || c.Autocomplete == nil {
+		// Autocomplete can only be answered with choices, so offer none.
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{
+				Choices: []*discordgo.ApplicationCommandOptionChoice{},
+			},
+		})
+		return
+	}
+
+	c.Autocomplete(s, i, args)
+}
+
+// resolve finds the command i runs and parses its arguments.
+func resolve(i *discordgo.InteractionCreate, partial bool) (*Command, Args, error) {
+	data, ok := i.Data.(discordgo.ApplicationCommandInteractionData)
+	if !ok {
+		return nil, Args{}, fmt.Errorf("I couldn't read the command")
+	}
+
+	c, ok := byName[data.Name]
+	if !ok {
+		return nil, Args{}, fmt.Errorf("I don't know /%s anymore", data.Name)
+	}
+
+	if !enabled(c.Name) {
+		return nil, Args{}, fmt.Errorf("/%s is turned off", c.Name)
+	}
+
+	user := User(i)
+	if user == nil {
+		return nil, Args{}, fmt.Errorf("I can't tell who you are")
+	}
+
+	if len(i.ChannelID) == 0 {
+		return nil, Args{}, fmt.Errorf("I can't tell where this is coming from")
+	}
+
+	args, err := parseArgs(c, data, partial)
+	if err != nil {
+		return nil, Args{}, err
+	}
+	args.User = user
+
+	return c, args, nil
+}
+
+// routeCustomId hands a button, select or modal to its handler, if the
+// command it belongs to is turned on and the member may run it.
+func routeCustomId(s *discordgo.Session, i *discordgo.InteractionCreate, handlers map[string]custom, customId string, stale string) {
+	prefix, _, _ := strings.Cut(customId, ":")
+
+	h, ok := handlers[prefix]
+	if !ok {
+		Fail(s, i, stale)
+		return
+	}
+
+	if User(i) == nil || len(i.ChannelID) == 0 {
+		Fail(s, i, "I can't tell who you are or where this is coming from.")
+		return
+	}
+
+	c := byName[h.command]
+	if !enabled(c.Name) {
+		Fail(s, i, fmt.Sprintf("/%s is turned off.", c.Name))
+		return
+	}
+
+	if !permission.Allowed(s, i, c.Capability) {
+		return
+	}
+
+	h.handler(s, i)
+}
+
+// User is who sent i: the member in a server, or the user in a DM. The
+// router makes sure there is one before calling any handler.
+func User(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// Fail answers i with an error only the member who sent it sees.
+func Fail(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         message,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+			Flags:           discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
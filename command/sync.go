@@ -0,0 +1,136 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// What syncing does to a command.
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Change is a command that differs between the registry and Discord, and
+// what syncing does about it.
+type Change struct {
+	Action string
+	Name   string
+	Type   discordgo.ApplicationCommandType
+}
+
+func (c Change) String() string {
+	if c.Type == discordgo.ChatApplicationCommand {
+		return fmt.Sprintf("%s /%s", c.Action, c.Name)
+	}
+	return fmt.Sprintf("%s %q", c.Action, c.Name)
+}
+
+// Sync brings the commands Discord has for guildId, or the global ones if
+// it is empty, in line with the registry. Whatever differs is applied in a
+// single bulk overwrite, and nothing is sent when nothing does. With dryRun
+// it only works out the changes.
+func Sync(s *discordgo.Session, appId string, guildId string, dryRun bool) ([]Change, error) {
+	have, err := s.ApplicationCommands(appId, guildId)
+	if err != nil {
+		return nil, err
+	}
+
+	want := Definitions()
+	changes := Diff(want, have)
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	if _, err := s.ApplicationCommandBulkOverwrite(appId, guildId, want); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Diff lists what it takes to turn have, the commands Discord has, into
+// want: the commands to create and update in want's order, then the ones to
+// delete in have's. Commands are the same if their type and name are, and
+// only the parts the registry sets are compared.
+func Diff(want []*discordgo.ApplicationCommand, have []*discordgo.ApplicationCommand) []Change {
+	type key struct {
+		name string
+		ty   discordgo.ApplicationCommandType
+	}
+
+	existing := make(map[key]*discordgo.ApplicationCommand, len(have))
+	for _, c := range have {
+		existing[key{c.Name, commandType(c)}] = c
+	}
+
+	var changes []Change
+	wanted := make(map[key]bool, len(want))
+	for _, c := range want {
+		k := key{c.Name, commandType(c)}
+		wanted[k] = true
+
+		old, ok := existing[k]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Create, c.Name, k.ty})
+		case !same(c, old):
+			changes = append(changes, Change{Update, c.Name, k.ty})
+		}
+	}
+
+	for _, c := range have {
+		k := key{c.Name, commandType(c)}
+		if !wanted[k] {
+			changes = append(changes, Change{Delete, c.Name, k.ty})
+		}
+	}
+
+	return changes
+}
+
+// commandType is c's type, counting unset as a slash command like Discord
+// does.
+func commandType(c *discordgo.ApplicationCommand) discordgo.ApplicationCommandType {
+	if c.Type == 0 {
+		return discordgo.ChatApplicationCommand
+	}
+	return c.Type
+}
+
+func same(a *discordgo.ApplicationCommand, b *discordgo.ApplicationCommand) bool {
+	return a.Description == b.Description && canonical(a.Options) == canonical(b.Options)
+}
+
+// canonical encodes options so that ones Discord sent back compare equal to
+// the ones they were created from, which leave empty lists unset.
+func canonical(options []*discordgo.ApplicationCommandOption) string {
+	encoded, err := json.Marshal(trim(options))
+	if err != nil {
+		return err.Error()
+	}
+	return string(encoded)
+}
+
+func trim(options []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	if len(options) == 0 {
+		return nil
+	}
+
+	trimmed := make([]*discordgo.ApplicationCommandOption, len(options))
+	for n, o := range options {
+		c := *o
+		if len(c.ChannelTypes) == 0 {
+			c.ChannelTypes = nil
+		}
+		if len(c.Choices) == 0 {
+			c.Choices = nil
+		}
+		c.Options = trim(c.Options)
+		trimmed[n] = &c
+	}
+	return trimmed
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDiff(t *testing.T) {
+	want := []*discordgo.ApplicationCommand{
+		{Name: "kept", Description: "Stays", Options: testCommand.Options},
+		{Name: "changed", Description: "New description"},
+		{Name: "added", Description: "Is new"},
+		{Name: "Remind me about this", Type: discordgo.MessageApplicationCommand},
+	}
+
+	// What Discord sends back has ids, explicit types and empty lists.
+	var options []*discordgo.ApplicationCommandOption
+	for _, o := range testCommand.Options {
+		c := *o
+		c.ChannelTypes = []discordgo.ChannelType{}
+		if c.Choices == nil {
+			c.Choices = []*discordgo.ApplicationCommandOptionChoice{}
+		}
+		options = append(options, &c)
+	}
+
+	have := []*discordgo.ApplicationCommand{
+		{ID: "1", Name: "removed", Type: discordgo.ChatApplicationCommand, Description: "Is gone"},
+		{ID: "2", Name: "kept", Type: discordgo.ChatApplicationCommand, Description: "Stays", Options: options},
+		{ID: "3", Name: "changed", Type: discordgo.ChatApplicationCommand, Description: "Old description"},
+		{ID: "4", Name: "Remind me about this", Type: discordgo.MessageApplicationCommand},
+	}
+
+	got := Diff(want, have)
+	expected := []Change{
+		{Update, "changed", discordgo.ChatApplicationCommand},
+		{Create, "added", discordgo.ChatApplicationCommand},
+		{Delete, "removed", discordgo.ChatApplicationCommand},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Diff = %v, want %v", got, expected)
+	}
+
+	if changes := Diff(want, want); len(changes) > 0 {
+		t.Errorf("commands differ from themselves: %v", changes)
+	}
+}
@@ -0,0 +1,254 @@
+// Package config reads the bot's settings from a TOML file. Environment
+// variables override the file, and flags override both.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"rawrippers.com/grumpy-daemon/store"
+)
+
+// Config is everything the bot can be configured with. Only Stable,
+// Features, Reminders and Shutdown take effect on a reload; the rest need a
+// restart.
+type Config struct {
+	Discord   Discord   `toml:"discord"`
+	Store     Store     `toml:"store"`
+	Reminders Reminders `toml:"reminders"`
+	Stable    Stable    `toml:"stable"`
+	Features  Features  `toml:"features"`
+	Shutdown  Shutdown  `toml:"shutdown"`
+}
+
+type Discord struct {
+	// Token is the bot token. TokenFile names a file to read it from
+	// instead, to keep it out of the config.
+	Token     string `toml:"token"`
+	TokenFile string `toml:"token_file"`
+	// Guilds are the guilds to sync commands to. With none they are
+	// global.
+	Guilds         []string `toml:"guilds"`
+	RemoveCommands bool     `toml:"remove_commands"`
+}
+
+type Store struct {
+	// DataDir is where data is kept, ~/.grumpy if empty.
+	DataDir string `toml:"data_dir"`
+	Backend string `toml:"backend"`
+}
+
+type Reminders struct {
+	CatchUp       string        `toml:"catchup"`
+	CatchUpMaxAge time.Duration `toml:"catchup_max_age"`
+	CatchUpReplay bool          `toml:"catchup_replay"`
+}
+
+type Stable struct {
+	URL       string `toml:"url"`
+	SubmitURL string `toml:"submit_url"`
+	StatusURL string `toml:"status_url"`
+	// MaxTries is how many times to ask whether an image is done, waiting
+	// PollInterval in between.
+	MaxTries     int           `toml:"max_tries"`
+	PollInterval time.Duration `toml:"poll_interval"`
+	// MaxSize is the widest and tallest image anyone may ask for.
+	MaxSize int64 `toml:"max_size"`
+}
+
+type Features struct {
+	// Disabled are commands to turn off. Which commands there are is only
+	// known once they are registered, so the caller checks the names.
+	Disabled []string `toml:"disabled"`
+}
+
+type Shutdown struct {
+	// Timeout is how long to wait for in-flight work when shutting down.
+	Timeout time.Duration `toml:"timeout"`
+}
+
+// Default is the configuration without a file.
+func Default() *Config {
+	return &Config{
+		Store: Store{Backend: store.Bolt},
+		Reminders: Reminders{
+			CatchUp:       "late",
+			CatchUpMaxAge: 6 * time.Hour,
+		},
+		Stable: Stable{
+			MaxTries:     50,
+			PollInterval: 3 * time.Second,
+			MaxSize:      1024,
+		},
+		Shutdown: Shutdown{Timeout: 30 * time.Second},
+	}
+}
+
+// Load reads the file at path over the defaults, or only takes the defaults
+// if path is empty. Then it applies the environment through getenv, lets
+// override apply flags, reads the token file and checks the result, apart
+// from the reminder and feature settings. The errors say which setting is
+// wrong and, for the file, where.
+func Load(path string, getenv func(string) string, override func(*Config)) (*Config, error) {
+	c := Default()
+
+	if len(path) > 0 {
+		if err := c.decode(path); err != nil {
+			return nil, err
+		}
+	}
+
+	c.env(getenv)
+	if override != nil {
+		override(c)
+	}
+
+	if err := c.readToken(); err != nil {
+		return nil, err
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *Config) decode(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			return fmt.Errorf("%s:%d:%d: %s", path, perr.Position.Line, perr.Position.Col, perr.Message)
+		}
+		// Type mismatches say which line and key themselves.
+		return fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "toml: "))
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for n, key := range undecoded {
+			keys[n] = key.String()
+		}
+		return fmt.Errorf("%s: unknown settings %s", path, strings.Join(keys, ", "))
+	}
+
+	return nil
+}
+
+// env applies the environment variables that override the file.
+func (c *Config) env(getenv func(string) string) {
+	set := func(name string, setting *string) {
+		if value := getenv(name); len(value) > 0 {
+			*setting = value
+		}
+	}
+
+	if token := getenv("GRUMPY_TOKEN"); len(token) > 0 {
+		c.Discord.Token = token
+		c.Discord.TokenFile = ""
+	}
+	if guilds := getenv("GRUMPY_GUILDS"); len(guilds) > 0 {
+		c.Discord.Guilds = SplitList(guilds)
+	}
+	set("GRUMPY_DATA_DIR", &c.Store.DataDir)
+	set("STABLE_URL", &c.Stable.URL)
+	set("STABLE_SUBMIT_URL", &c.Stable.SubmitURL)
+	set("STABLE_STATUS_URL", &c.Stable.StatusURL)
+}
+
+func (c *Config) readToken() error {
+	if len(c.Discord.TokenFile) == 0 {
+		return nil
+	}
+	if len(c.Discord.Token) > 0 {
+		return fmt.Errorf("discord.token and discord.token_file are both set, use one")
+	}
+
+	token, err := os.ReadFile(c.Discord.TokenFile)
+	if err != nil {
+		return fmt.Errorf("discord.token_file: %w", err)
+	}
+	c.Discord.Token = strings.TrimSpace(string(token))
+	return nil
+}
+
+func (c *Config) validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.Discord.Token) == 0 {
+		problem("no bot token: set discord.token or discord.token_file, GRUMPY_TOKEN or -token")
+	}
+	for n, guild := range c.Discord.Guilds {
+		if !snowflake(guild) {
+			problem("discord.guilds[%d]: %q isn't a guild ID", n, guild)
+		}
+	}
+
+	if c.Store.Backend != store.Bolt && c.Store.Backend != store.JSON {
+		problem("store.backend: unknown backend %q, use %s or %s", c.Store.Backend, store.Bolt, store.JSON)
+	}
+
+	for _, endpoint := range []struct{ name, url string }{
+		{"stable.url", c.Stable.URL},
+		{"stable.submit_url", c.Stable.SubmitURL},
+		{"stable.status_url", c.Stable.StatusURL},
+	} {
+		name, endpoint := endpoint.name, endpoint.url
+		if len(endpoint) == 0 {
+			continue
+		}
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			problem("%s: %q isn't an http or https URL", name, endpoint)
+		}
+	}
+	if c.Stable.MaxTries < 1 {
+		problem("stable.max_tries: has to be at least 1, not %d", c.Stable.MaxTries)
+	}
+	if c.Stable.PollInterval <= 0 {
+		problem("stable.poll_interval: has to be positive, not %s", c.Stable.PollInterval)
+	}
+	if c.Stable.MaxSize < 64 {
+		problem("stable.max_size: has to be at least 64, not %d", c.Stable.MaxSize)
+	}
+
+	if c.Shutdown.Timeout <= 0 {
+		problem("shutdown.timeout: has to be positive, not %s", c.Shutdown.Timeout)
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SplitList splits a comma separated list, like a flag's, dropping blanks.
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func snowflake(id string) bool {
+	if len(id) == 0 {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func write(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoad(t *testing.T) {
+	token := write(t, "token", "secret\n")
+	path := write(t, "grumpy.toml", `
+[discord]
+token_file = "`+token+`"
+guilds = ["1", "2"]
+
+[stable]
+url = "http://localhost:8000"
+poll_interval = "1s"
+`)
+
+	c, err := Load(path, env(map[string]string{"STABLE_URL": "https://sd.example.com"}), func(c *Config) {
+		c.Discord.Guilds = []string{"3"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Discord.Token != "secret" {
+		t.Errorf("token %q", c.Discord.Token)
+	}
+	if len(c.Discord.Guilds) != 1 || c.Discord.Guilds[0] != "3" {
+		t.Errorf("flags didn't override guilds: %v", c.Discord.Guilds)
+	}
+	if c.Stable.URL != "https://sd.example.com" {
+		t.Errorf("environment didn't override stable.url: %q", c.Stable.URL)
+	}
+	if c.Stable.PollInterval != time.Second || c.Stable.MaxTries != 50 {
+		t.Errorf("stable %+v", c.Stable)
+	}
+	if c.Shutdown.Timeout != 30*time.Second {
+		t.Errorf("default shutdown timeout %s", c.Shutdown.Timeout)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	withToken := env(map[string]string{"GRUMPY_TOKEN": "secret"})
+
+	bad := map[string]string{
+		"grumpy.toml: line 3 (last key \"stable.max_tries\")": "[stable]\nurl = \"http://localhost\"\nmax_tries = \"many\"\n",
+		"grumpy.toml:2:":                                  "[stable]\nurl = \n",
+		"unknown settings stable.urll":                    "[stable]\nurll = \"http://localhost\"\n",
+		"discord.guilds[1]: \"general\" isn't a guild ID": "[discord]\nguilds = [\"1\", \"general\"]\n",
+		"store.backend: unknown backend \"sqlite\"":       "[store]\nbackend = \"sqlite\"\n",
+		"stable.status_url: \"localhost:8000\" isn't":     "[stable]\nstatus_url = \"localhost:8000\"\n",
+	}
+
+	for want, content := range bad {
+		_, err := Load(write(t, "grumpy.toml", content), withToken, nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want an error saying %q", err, want)
+		}
+	}
+
+	if _, err := Load("", env(nil), nil); err == nil || !strings.Contains(err.Error(), "no bot token") {
+		t.Errorf("loaded without a token: %v", err)
+	}
+}
+
+func TestExample(t *testing.T) {
+	_, err := Load("../docs/grumpy.example.toml", env(map[string]string{"GRUMPY_TOKEN": "secret"}), nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -4,22 +4,27 @@ import (
 	"fmt"
 
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
 )
 
-func FirstOfTheMonth(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func init() {
+	command.Register(&command.Command{
+		Name:        "first",
+		Description: "First of the month",
+		Handler:     FirstOfTheMonth,
+	})
+}
+
+func FirstOfTheMonth(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: postFirst(i),
+			Content: postFirst(args.User),
 		},
 	})
 }
 
-func postFirst(i *discordgo.InteractionCreate) string {
-	if i.Member != nil && i.Member.User != nil {
-		username := fmt.Sprintf("<@%s>", i.Member.User.ID)
-		return fmt.Sprintf("%s says wake up!\nhttps://www.youtube.com/watch?v=4j_cOsgRY7w", username)
-	} else {
-		return "Wake up!\nhttps://www.youtube.com/watch?v=4j_cOsgRY7w"
-	}
+func postFirst(user *discordgo.User) string {
+	username := fmt.Sprintf("<@%s>", user.ID)
+	return fmt.Sprintf("%s says wake up!\nhttps://www.youtube.com/watch?v=4j_cOsgRY7w", username)
 }
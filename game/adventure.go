@@ -1,11 +1,13 @@
 package game
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
 
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
 )
 
 var (
@@ -13,58 +15,60 @@ var (
 	adventure *GameProc
 )
 
-func Adventure(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	mu.Lock()
-	if adventure == nil || !adventure.started {
-		log.Print("Starting a new game")
-		adventure = New("adventure")
-	} else {
-		log.Print("Game is already started")
-	}
-	mu.Unlock()
+func init() {
+	command.Register(&command.Command{
+		Name:        "adventure",
+		Description: "play the Adventure text based game",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "command",
+				Description: "command to send to Adventure",
+				Required:    true,
+			},
+		},
+		Handler: Adventure,
+	})
+}
 
+func Adventure(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: adventureExecute(s, i),
+			Content: adventureExecute(s, i, args),
 		},
 	})
 }
 
-func adventureExecuteAndRespond(s *discordgo.Session, username string, channelId string, command string) {
-	mu.Lock()
-	response := adventure.Execute(command)
-	mu.Unlock()
-
-	s.ChannelMessageSend(channelId, response)
+func adventureExecuteAndRespond(ctx context.Context, s *discordgo.Session, channelId string, cmd string) {
+	s.ChannelMessageSend(channelId, execute(ctx, cmd))
 }
 
-func adventureExecute(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
-	}
+// execute sends cmd to the game, starting one if none is running. The game
+// is killed when ctx is done.
+func execute(ctx context.Context, cmd string) string {
+	mu.Lock()
+	defer mu.Unlock()
 
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
+	if adventure == nil || !adventure.started {
+		log.Print("Starting a new game")
+		adventure = New(ctx, "adventure")
+	} else {
+		log.Print("Game is already started")
 	}
 
-	username := fmt.Sprintf("<@%s>", i.Member.User.ID)
-	channelId := i.ChannelID
-
-	options := i.ApplicationCommandData().Options
+	return adventure.Execute(cmd)
+}
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
-	}
+func adventureExecute(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	username := fmt.Sprintf("<@%s>", args.User.ID)
+	channelId := i.ChannelID
 
-	if option, ok := optionMap["command"]; ok {
-		command := option.StringValue()
-		go adventureExecuteAndRespond(s, username, channelId, command)
-		return fmt.Sprintf("%s sent '%s'", username, command)
-	} else {
-		return "You broke it."
-	}
+	cmd := args.String("command")
+	command.Go(s, i, func(ctx context.Context) {
+		adventureExecuteAndRespond(ctx, s, channelId, cmd)
+	})
+	return fmt.Sprintf("%s sent '%s'", username, cmd)
 }
 
 func Stop() {
@@ -2,10 +2,12 @@ package game
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os/exec"
+	"sync"
 	"time"
 )
 
@@ -14,10 +16,12 @@ type GameProc struct {
 	stdin    io.WriteCloser
 	readChan chan string
 	started  bool
+	stop     sync.Once
 }
 
-func New(command string) *GameProc {
-	cmd := exec.Command("stdbuf", "-oL", command)
+// New starts command, which is killed when ctx is done.
+func New(ctx context.Context, command string) *GameProc {
+	cmd := exec.CommandContext(ctx, "stdbuf", "-oL", command)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		log.Print("Error piping stdin")
@@ -30,7 +34,7 @@ func New(command string) *GameProc {
 	go cmd.Wait()
 	readChan := make(chan string)
 
-	gameProc := GameProc{
+	gameProc := &GameProc{
 		command:  cmd,
 		stdin:    stdin,
 		readChan: readChan,
@@ -39,7 +43,7 @@ func New(command string) *GameProc {
 
 	go gameProc.startRead(stdout)
 
-	return &gameProc
+	return gameProc
 }
 
 func (game *GameProc) startRead(stdout io.ReadCloser) {
@@ -75,13 +79,17 @@ stdoutloop:
 	return result
 }
 
+// Stop kills the game. It may be called again, when the game's output
+// ends because it was already stopped.
 func (game *GameProc) Stop() {
-	defer game.stdin.Close()
-	defer close(game.readChan)
-	err := game.command.Process.Kill()
-	if err != nil {
-		log.Print("Couldn't kill process")
-	}
-	game.started = false
-	log.Print(game.started)
+	game.stop.Do(func() {
+		defer game.stdin.Close()
+		defer close(game.readChan)
+		err := game.command.Process.Kill()
+		if err != nil {
+			log.Print("Couldn't kill process")
+		}
+		game.started = false
+		log.Print(game.started)
+	})
 }
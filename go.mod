@@ -3,8 +3,13 @@ module rawrippers.com/grumpy-daemon
 go 1.19
 
 require (
-	github.com/bcampbell/fuzzytime v0.0.0-20191010161914-05ea0010feac // indirect
-	github.com/bwmarrin/discordgo v0.26.0 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/bcampbell/fuzzytime v0.0.0-20191010161914-05ea0010feac
+	github.com/bwmarrin/discordgo v0.26.0
+	go.etcd.io/bbolt v1.3.6
+)
+
+require (
 	github.com/gorilla/websocket v1.4.2 // indirect
 	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
@@ -1,25 +1,44 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"rawrippers.com/grumpy-daemon/first"
+	"rawrippers.com/grumpy-daemon/command"
 	"rawrippers.com/grumpy-daemon/game"
-	"rawrippers.com/grumpy-daemon/joke"
+	"rawrippers.com/grumpy-daemon/permission"
 	"rawrippers.com/grumpy-daemon/reaction"
 	"rawrippers.com/grumpy-daemon/reminder"
 	"rawrippers.com/grumpy-daemon/response"
-	"rawrippers.com/grumpy-daemon/stable"
+	"rawrippers.com/grumpy-daemon/store"
+	"rawrippers.com/grumpy-daemon/timezone"
+
+	// Packages that only register commands.
+	_ "rawrippers.com/grumpy-daemon/first"
+	_ "rawrippers.com/grumpy-daemon/joke"
+	_ "rawrippers.com/grumpy-daemon/stable"
 )
 
+// Flags override the config file and the environment when they are given.
 var (
-	GuildID        = flag.String("guild", "", "Test guild ID. If not passed - bot registers commands globally")
-	BotToken       = flag.String("token", "", "Bot access token")
-	RemoveCommands = flag.Bool("rmcmd", true, "Remove all commands after shutdowning or not")
+	ConfigFile      = flag.String("config", "", "TOML config file (default $GRUMPY_CONFIG, or none)")
+	GuildID         = flag.String("guild", "", "Guild IDs to sync commands to, comma separated. If not passed - bot registers commands globally")
+	BotToken        = flag.String("token", "", "Bot access token")
+	RemoveCommands  = flag.Bool("rmcmd", false, "Remove all commands after shutting down")
+	SyncOnly        = flag.Bool("sync-only", false, "Print how syncing would change the registered commands and exit")
+	ShutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "How long to wait for in-flight work like image jobs when shutting down")
+	CatchUp         = flag.String("catchup", reminder.CatchUpLate, "What to do with reminders missed while down: late, skip or summary")
+	CatchUpMaxAge   = flag.Duration("catchup-max-age", 6*time.Hour, "With -catchup=skip, drop missed reminders older than this")
+	CatchUpReplay   = flag.Bool("catchup-replay", false, "Replay every missed occurrence of recurring reminders instead of only the latest")
+	DataDir         = flag.String("data-dir", "", "Where to keep reminders, responses and reactions (default ~/.grumpy)")
+	StoreBackend    = flag.String("store", store.Bolt, "How to store data: bolt for a single database file, json for one file per kind")
 )
 
 var s *discordgo.Session
@@ -28,281 +47,167 @@ func init() {
 	flag.Parse()
 }
 
-func init() {
-	var err error
-	s, err = discordgo.New("Bot " + *BotToken)
+func main() {
+	c, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf = c
+	apply(c)
+
+	s, err = discordgo.New("Bot " + c.Discord.Token)
 	if err != nil {
 		log.Fatalf("Invalid bot parameters: %v", err)
 	}
-}
+	s.AddHandler(command.Route)
 
-var (
-	commands = []*discordgo.ApplicationCommand{
-		{
-			Name:        "joke",
-			Description: "Tell a joke",
-		},
-		{
-			Name:        "first",
-			Description: "First of the month",
-		},
-		{
-			Name:        "stable",
-			Description: "Stable diffusion",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "prompt",
-					Description: "Stable diffusion prompt",
-					Required:    true,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Name:        "width",
-					Description: "width",
-					Required:    false,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Name:        "height",
-					Description: "height",
-					Required:    false,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Name:        "num_outputs",
-					Description: "number of images",
-					Required:    false,
-					MaxValue:    4,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionNumber,
-					Name:        "guidance_scale",
-					Description: "guidance scale",
-					Required:    false,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionNumber,
-					Name:        "prompt_strength",
-					Description: "prompt strength",
-					Required:    false,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Name:        "num_inference_steps",
-					Description: "number of inference steps",
-					Required:    false,
-				},
-			},
-		},
-		{
-			Name:        "adventure",
-			Description: "play the Adventure text based game",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "command",
-					Description: "command to send to Adventure",
-					Required:    true,
-				},
-			},
-		},
-		{
-			Name:        "reminder",
-			Description: "set a channel reminder",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "message",
-					Description: "message to post",
-					Required:    true,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "when",
-					Description: "e.g. 2022-10-29 08:43 -0400",
-					Required:    true,
-				},
-			},
-		},
-		{
-			Name:        "response",
-			Description: "set a channel response",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "message",
-					Description: "message to post",
-					Required:    true,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "search",
-					Description: "e.g. search that will trigger response",
-					Required:    true,
-				},
-			},
-		},
-		{
-			Name:        "list_reminders",
-			Description: "list all channel reminders",
-		},
-		{
-			Name:        "list_responses",
-			Description: "list all channel responses",
-		},
-		{
-			Name:        "delete_reminder",
-			Description: "delete a reminder",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "message",
-					Description: "message to delete",
-					Required:    true,
-				},
-			},
-		},
-		{
-			Name:        "delete_response",
-			Description: "delete a response",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "search",
-					Description: "message to delete (by search)",
-					Required:    true,
-				},
-			},
-		},
-		{
-			Name:        "reaction",
-			Description: "set a channel message reaction",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "emoji",
-					Description: "emoji reaction",
-					Required:    true,
-				},
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "search",
-					Description: "e.g. search that will trigger reaction",
-					Required:    true,
-				},
-			},
-		},
-		{
-			Name:        "list_reactions",
-			Description: "list all channel reactions",
-		},
-		{
-			Name:        "delete_reaction",
-			Description: "delete a reaction",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "search",
-					Description: "reaction to delete (by search)",
-					Required:    true,
-				},
-			},
-		},
+	if *SyncOnly {
+		me, err := s.User("@me")
+		if err != nil {
+			log.Fatalf("Cannot log in: %v", err)
+		}
+		if err := syncCommands(me.ID, c.Discord.Guilds, true); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
-		"joke": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			joke.Joke(s, i)
-		},
-		"first": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			first.FirstOfTheMonth(s, i)
-		},
-		"stable": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			stable.Stable(s, i)
-		},
-		"adventure": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			game.Adventure(s, i)
-		},
-		"reminder": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			reminder.SetReminder(s, i)
-		},
-		"list_reminders": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			reminder.ListReminders(s, i)
-		},
-		"response": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			response.SetResponse(s, i)
-		},
-		"list_responses": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			response.ListResponses(s, i)
-		},
-		"delete_response": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			response.DeleteResponse(s, i)
-		},
-		"delete_reminder": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			reminder.DeleteReminder(s, i)
-		},
-		"reaction": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			reaction.SetReaction(s, i)
-		},
-		"list_reactions": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			reaction.ListReactions(s, i)
-		},
-		"delete_reaction": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			reaction.DeleteReaction(s, i)
-		},
+	dataDir := c.Store.DataDir
+	if len(dataDir) == 0 {
+		dataDir, err = store.DefaultDir()
+		if err != nil {
+			log.Fatalf("Cannot find a data directory: %v", err)
+		}
 	}
-)
 
-func init() {
-	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
+	st, err := store.Open(dataDir, c.Store.Backend)
+	if err != nil {
+		log.Fatalf("Cannot open the store: %v", err)
+	}
+	defer st.Close()
+
+	// Starting without data that failed to load would save over it.
+	for _, load := range []func(*store.Store) error{
+		permission.Load,
+		timezone.Load,
+		reminder.Load,
+		response.Load,
+		reaction.Load,
+	} {
+		if err := load(st); err != nil {
+			st.Close()
+			log.Fatalf("Cannot load data: %v", err)
 		}
-	})
-}
+	}
 
-func main() {
-	go reminder.Poll(s)
-	go response.Load()
-	go reaction.Load()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	command.SetContext(ctx)
+
+	polled := make(chan struct{})
+	go func() {
+		reminder.Poll(ctx, s)
+		close(polled)
+	}()
 
 	s.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
-	s.AddHandler(response.MessageCreate)
-	s.AddHandler(reaction.MessageCreate)
-	err := s.Open()
+	s.AddHandler(command.Message(response.MessageCreate))
+	s.AddHandler(command.Message(reaction.MessageCreate))
+	err = s.Open()
 	if err != nil {
 		log.Fatalf("Cannot open the session: %v", err)
 	}
 
-	log.Println("Adding commands...")
-
-	registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
-	for i, v := range commands {
-		cmd, err := s.ApplicationCommandCreate(s.State.User.ID, *GuildID, v)
-		if err != nil {
-			log.Panicf("Cannot create '%v' command: %v", v.Name, err)
-		}
-		registeredCommands[i] = cmd
+	log.Println("Syncing commands...")
+	if err := syncCommands(s.State.User.ID, c.Discord.Guilds, false); err != nil {
+		log.Fatal(err)
 	}
 
 	defer s.Close()
 	defer game.Stop()
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	<-stop
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	var sig os.Signal
+	for sig = range stop {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if message, err := reload(); err != nil {
+			log.Printf("Cannot reload the configuration: %v", err)
+		} else {
+			log.Println(message)
+		}
+	}
+	// A second signal kills the bot without waiting.
+	signal.Stop(stop)
+
+	c = current()
+	log.Printf("Got %v, finishing in-flight work for up to %v...", sig, c.Shutdown.Timeout)
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), c.Shutdown.Timeout)
+	defer cancelDeadline()
 
-	if *RemoveCommands {
+	if err := command.Drain(deadline); err != nil {
+		log.Printf("Gave up waiting for in-flight work: %v", err)
+	}
+	cancel()
+
+	// The store closes when main returns, so the reminders have to be
+	// saved first however long that takes.
+	<-polled
+
+	if c.Discord.RemoveCommands {
 		log.Println("Removing commands..")
-		for _, v := range registeredCommands {
-			err := s.ApplicationCommandDelete(s.State.User.ID, *GuildID, v.ID)
+		for _, guildId := range targets(c.Discord.Guilds) {
+			_, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, guildId, []*discordgo.ApplicationCommand{})
 			if err != nil {
-				log.Panicf("Cannot delete '%v' command: %v", v.Name, err)
+				log.Printf("Cannot remove commands %s: %v", where(guildId), err)
 			}
 		}
 	}
 
 	log.Println("Gracefully shutting down.")
 }
+
+// syncCommands brings the commands of every target in line with the
+// registry, or with dryRun prints what that would change.
+func syncCommands(appId string, guilds []string, dryRun bool) error {
+	for _, guildId := range targets(guilds) {
+		changes, err := command.Sync(s, appId, guildId, dryRun)
+		if err != nil {
+			return fmt.Errorf("cannot sync commands %s: %w", where(guildId), err)
+		}
+
+		switch {
+		case dryRun:
+			fmt.Printf("Commands %s: %d to change\n", where(guildId), len(changes))
+			for _, c := range changes {
+				fmt.Printf("  %s\n", c)
+			}
+		case len(changes) == 0:
+			log.Printf("Commands %s are up to date", where(guildId))
+		default:
+			for _, c := range changes {
+				log.Printf("Synced commands %s: %s", where(guildId), c)
+			}
+		}
+	}
+	return nil
+}
+
+// targets are the guilds to sync commands to, or "" for global commands.
+func targets(guilds []string) []string {
+	if len(guilds) == 0 {
+		return []string{""}
+	}
+	return guilds
+}
+
+func where(guildId string) string {
+	if len(guildId) == 0 {
+		return "globally"
+	}
+	return "in guild " + guildId
+}
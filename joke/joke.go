@@ -2,27 +2,44 @@ package joke
 
 import (
 	"bufio"
+	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
 )
 
-func Joke(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func init() {
+	command.Register(&command.Command{
+		Name:        "joke",
+		Description: "Tell a joke",
+		Handler:     Joke,
+	})
+}
+
+func Joke(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	joke, err := randomJoke()
+	if err != nil {
+		log.Printf("could not pick a joke: %s", err)
+		command.Fail(s, i, "I can't think of a joke right now.")
+		return
+	}
+
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: randomJoke(),
+			Content: joke,
 		},
 	})
 }
 
-func randomJoke() string {
+func randomJoke() (string, error) {
 	file, err := os.Open("data/jokes.txt")
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer file.Close()
 
@@ -33,12 +50,16 @@ func randomJoke() string {
 		jokes = append(jokes, scanner.Text())
 	}
 
-	joke := jokes[rand.Intn(len(jokes))]
-	joke = strings.Replace(joke, "<>", "\n", -1)
-
 	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+
+	if len(jokes) == 0 {
+		return "", fmt.Errorf("%s has no jokes", file.Name())
 	}
 
-	return joke
+	joke := jokes[rand.Intn(len(jokes))]
+	joke = strings.Replace(joke, "<>", "\n", -1)
+
+	return joke, nil
 }
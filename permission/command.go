@@ -0,0 +1,147 @@
+package permission
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Permissions handles /grumpy permissions view, grant and revoke. The router
+// has already checked that the member is an admin. capability and roleId
+// are only used by grant and revoke.
+func Permissions(s *discordgo.Session, i *discordgo.InteractionCreate, action string, capability Capability, roleId string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         permissions(i, action, capability, roleId),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+			Flags:           discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func permissions(i *discordgo.InteractionCreate, action string, capability Capability, roleId string) string {
+	if len(i.GuildID) == 0 {
+		return "Permissions are set per server, use this in one."
+	}
+
+	switch action {
+	case "view":
+		return view(i.GuildID)
+	case "grant", "revoke":
+		if !known(capability) {
+			return fmt.Sprintf("I don't know the capability `%s`.", capability)
+		}
+
+		if action == "grant" {
+			return grantRole(i, capability, roleId)
+		}
+		return revokeRole(i, capability, roleId)
+	}
+
+	return "Use /grumpy permissions view, grant or revoke."
+}
+
+func grantRole(i *discordgo.InteractionCreate, capability Capability, roleId string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	first := true
+	for _, g := range guildGrants(i.GuildID) {
+		if g.Capability != capability {
+			continue
+		}
+		if g.RoleId == roleId {
+			return fmt.Sprintf("<@&%s> already has `%s`.", roleId, capability)
+		}
+		first = false
+	}
+
+	grants = append(grants, &grant{GuildId: i.GuildID, RoleId: roleId, Capability: capability})
+	write()
+
+	log.Printf("audit: user %s granted %s to role %s in guild %s", userId(i), capability, roleId, i.GuildID)
+
+	if first {
+		return fmt.Sprintf("<@&%s> now has `%s`. Until it is revoked, members without a role that has it no longer get it by default (%s).", roleId, capability, fallbackText(capability))
+	}
+
+	return fmt.Sprintf("<@&%s> now has `%s`.", roleId, capability)
+}
+
+func revokeRole(i *discordgo.InteractionCreate, capability Capability, roleId string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	found := false
+	kept := grants[:0]
+	for _, g := range grants {
+		if g.GuildId == i.GuildID && g.RoleId == roleId && g.Capability == capability {
+			found = true
+			continue
+		}
+		kept = append(kept, g)
+	}
+	grants = kept
+
+	if !found {
+		return fmt.Sprintf("<@&%s> doesn't have `%s`.", roleId, capability)
+	}
+
+	write()
+
+	log.Printf("audit: user %s revoked %s from role %s in guild %s", userId(i), capability, roleId, i.GuildID)
+
+	return fmt.Sprintf("<@&%s> no longer has `%s`.", roleId, capability)
+}
+
+// view describes who has each capability in a guild.
+func view(guildId string) string {
+	mu.Lock()
+	guild := guildGrants(guildId)
+	mu.Unlock()
+
+	var lines []string
+	for _, capability := range Capabilities {
+		var roles []string
+		for _, g := range guild {
+			if g.Capability == capability {
+				roles = append(roles, fmt.Sprintf("<@&%s>", g.RoleId))
+			}
+		}
+
+		if len(roles) == 0 {
+			lines = append(lines, fmt.Sprintf("`%s`: %s", capability, fallbackText(capability)))
+		} else {
+			lines = append(lines, fmt.Sprintf("`%s`: %s", capability, strings.Join(roles, ", ")))
+		}
+	}
+
+	lines = append(lines, "Admins have everything, and `delete-any` includes `delete-own`.")
+
+	return strings.Join(lines, "\n")
+}
+
+// fallbackText says who has a capability no role is mapped to.
+func fallbackText(capability Capability) string {
+	switch capability {
+	case Create, DeleteOwn:
+		return "everyone"
+	case DeleteAny:
+		return "members who can Manage Messages"
+	case Admin:
+		return "members who can Manage Server"
+	}
+	return "nobody"
+}
+
+func known(capability Capability) bool {
+	for _, c := range Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
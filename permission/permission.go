@@ -0,0 +1,217 @@
+package permission
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/store"
+)
+
+// Capability is something a command needs the member running it to be
+// allowed to do.
+type Capability string
+
+// Capabilities. Admin includes all the others and DeleteAny includes
+// DeleteOwn. Commands that need none, like lists, declare "".
+const (
+	Create    Capability = "create"
+	DeleteOwn Capability = "delete-own"
+	DeleteAny Capability = "delete-any"
+	Admin     Capability = "admin"
+)
+
+// Capabilities lists every capability, for the /grumpy permissions choices.
+var Capabilities = []Capability{Create, DeleteOwn, DeleteAny, Admin}
+
+// grant is how a role being mapped to a capability in a guild is saved.
+type grant struct {
+	GuildId    string
+	RoleId     string
+	Capability Capability
+}
+
+var (
+	mu     sync.Mutex
+	grants []*grant
+	repo   store.Repository[*grant]
+)
+
+func Load(st *store.Store) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loading := store.Collection[*grant](st, "permissions")
+
+	loaded, err := loading.Load()
+	if err != nil {
+		return fmt.Errorf("could not load permissions: %w", err)
+	}
+	repo = loading
+
+	grants = loaded
+	log.Printf("loaded %d permission grants", len(grants))
+
+	return nil
+}
+
+// Has reports whether the member who sent i has capability in the guild it
+// was sent in. Outside a guild there are no roles to map, so anyone may
+// create and delete their own things there and nobody is an admin.
+func Has(i *discordgo.InteractionCreate, capability Capability) bool {
+	if len(capability) == 0 {
+		return true
+	}
+
+	if i.Member == nil || len(i.GuildID) == 0 {
+		return capability == Create || capability == DeleteOwn
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return has(guildGrants(i.GuildID), i.Member, capability)
+}
+
+// Allowed is Has, but also tells the member and audit logs it when they
+// don't have capability. Handlers return when it reports false.
+func Allowed(s *discordgo.Session, i *discordgo.InteractionCreate, capability Capability) bool {
+	if Has(i, capability) {
+		return true
+	}
+
+	deny(i, capability, "/"+commandName(i))
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "You need the `" + string(capability) + "` permission for that. Ask an admin to check /grumpy permissions view.",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	return false
+}
+
+// MayDelete reports whether the member who sent i may delete or change
+// something authorId made, audit logging it if not. Things saved before
+// authors were recorded have none, so only members with DeleteAny may
+// delete those.
+func MayDelete(i *discordgo.InteractionCreate, authorId string, what string) bool {
+	if len(authorId) > 0 && authorId == userId(i) {
+		return true
+	}
+
+	if Has(i, DeleteAny) {
+		return true
+	}
+
+	deny(i, DeleteAny, what)
+	return false
+}
+
+// has decides whether member has capability given the guild's grants. A
+// capability no role is mapped to falls back to the Discord permission
+// that covers it, or to everyone for creating and deleting your own things.
+func has(guild []*grant, member *discordgo.Member, capability Capability) bool {
+	if member.Permissions&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+
+	roles := make(map[string]bool, len(member.Roles))
+	for _, role := range member.Roles {
+		roles[role] = true
+	}
+
+	for _, c := range including(capability) {
+		mapped := false
+		for _, g := range guild {
+			if g.Capability != c {
+				continue
+			}
+			mapped = true
+			if roles[g.RoleId] {
+				return true
+			}
+		}
+
+		if !mapped && fallback(member, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// including lists capability and the capabilities that include it.
+func including(capability Capability) []Capability {
+	switch capability {
+	case DeleteOwn:
+		return []Capability{DeleteOwn, DeleteAny, Admin}
+	case DeleteAny, Create:
+		return []Capability{capability, Admin}
+	}
+	return []Capability{capability}
+}
+
+func fallback(member *discordgo.Member, capability Capability) bool {
+	switch capability {
+	case Create, DeleteOwn:
+		return true
+	case DeleteAny:
+		return member.Permissions&discordgo.PermissionManageMessages != 0
+	case Admin:
+		return member.Permissions&discordgo.PermissionManageServer != 0
+	}
+	return false
+}
+
+// guildGrants returns the grants of one guild. mu must be held.
+func guildGrants(guildId string) []*grant {
+	var guild []*grant
+	for _, g := range grants {
+		if g.GuildId == guildId {
+			guild = append(guild, g)
+		}
+	}
+	return guild
+}
+
+// deny audit logs a member being refused.
+func deny(i *discordgo.InteractionCreate, capability Capability, what string) {
+	log.Printf("audit: denied %s to user %s in guild %s channel %s: needs %s", what, userId(i), i.GuildID, i.ChannelID, capability)
+}
+
+// userId is who sent i, in a server or a DM.
+func userId(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+func commandName(i *discordgo.InteractionCreate) string {
+	data, ok := i.Data.(discordgo.ApplicationCommandInteractionData)
+	if !ok {
+		return "interaction"
+	}
+	return data.Name
+}
+
+func write() {
+	sort.SliceStable(grants, func(a, b int) bool {
+		if grants[a].GuildId != grants[b].GuildId {
+			return grants[a].GuildId < grants[b].GuildId
+		}
+		return grants[a].Capability < grants[b].Capability
+	})
+
+	if err := repo.Save(grants); err != nil {
+		log.Printf("could not save permissions: %s", err)
+	}
+}
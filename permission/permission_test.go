@@ -0,0 +1,86 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestHas(t *testing.T) {
+	member := &discordgo.Member{Roles: []string{"helpers"}}
+	moderator := &discordgo.Member{Permissions: discordgo.PermissionManageMessages}
+	administrator := &discordgo.Member{Permissions: discordgo.PermissionAdministrator}
+
+	mapped := []*grant{
+		{GuildId: "1", RoleId: "makers", Capability: Create},
+		{GuildId: "1", RoleId: "helpers", Capability: DeleteAny},
+		{GuildId: "1", RoleId: "owners", Capability: Admin},
+	}
+
+	tests := []struct {
+		guild      []*grant
+		member     *discordgo.Member
+		capability Capability
+		want       bool
+	}{
+		// Nothing mapped falls back to Discord's permissions.
+		{nil, member, Create, true},
+		{nil, member, DeleteOwn, true},
+		{nil, member, DeleteAny, false},
+		{nil, moderator, DeleteAny, true},
+		{nil, moderator, Admin, false},
+		{nil, &discordgo.Member{Permissions: discordgo.PermissionManageServer}, Admin, true},
+		{nil, administrator, Admin, true},
+
+		// Mapped capabilities only go to the roles they are mapped to.
+		{mapped, member, Create, false},
+		{mapped, &discordgo.Member{Roles: []string{"makers"}}, Create, true},
+		{mapped, member, DeleteAny, true},
+		{mapped, member, DeleteOwn, true},
+		{mapped, moderator, DeleteAny, false},
+		{mapped, &discordgo.Member{Roles: []string{"owners"}}, DeleteAny, true},
+		{mapped, &discordgo.Member{Permissions: discordgo.PermissionManageServer}, Admin, false},
+		{mapped, administrator, Create, true},
+	}
+
+	for n, test := range tests {
+		if got := has(test.guild, test.member, test.capability); got != test.want {
+			t.Errorf("%d: has(%s) = %t, want %t", n, test.capability, got, test.want)
+		}
+	}
+}
+
+func TestMayDelete(t *testing.T) {
+	grants = nil
+
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		GuildID: "1",
+		Member:  &discordgo.Member{User: &discordgo.User{ID: "me"}},
+	}}
+
+	if !MayDelete(i, "me", "mine") {
+		t.Error("can't delete my own")
+	}
+	if MayDelete(i, "you", "yours") {
+		t.Error("deleted someone else's")
+	}
+	if MayDelete(i, "", "nobody's") {
+		t.Error("deleted something without an author")
+	}
+
+	i.Member.Permissions = discordgo.PermissionManageMessages
+	if !MayDelete(i, "you", "yours") {
+		t.Error("moderator can't delete someone else's")
+	}
+}
+
+func TestHasOutsideGuild(t *testing.T) {
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{User: &discordgo.User{ID: "me"}}}
+
+	if !Has(i, Create) || !Has(i, DeleteOwn) || !Has(i, "") {
+		t.Error("can't create in a DM")
+	}
+	if Has(i, Admin) {
+		t.Error("admin in a DM")
+	}
+}
@@ -0,0 +1,81 @@
+package reaction
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+	"rawrippers.com/grumpy-daemon/utils"
+)
+
+func init() {
+	command.Register(
+		&command.Command{
+			Name:        "reaction",
+			Description: "set a channel message reaction",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "emoji",
+					Description: "emoji reaction",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "search",
+					Description: "e.g. search that will trigger reaction",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "scope",
+					Description: "where it applies, this channel by default",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "this channel", Value: utils.ScopeChannel},
+						{Name: "this category", Value: utils.ScopeCategory},
+						{Name: "the whole server", Value: utils.ScopeGuild},
+					},
+				},
+			},
+			Capability: permission.Create,
+			Handler:    SetReaction,
+		},
+		&command.Command{
+			Name:        "list_reactions",
+			Description: "list all channel reactions",
+			Handler:     ListReactions,
+		},
+		&command.Command{
+			Name:        "delete_reaction",
+			Description: "delete a reaction",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "search",
+					Description:  "reaction to delete (by search)",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+			Capability:   permission.DeleteOwn,
+			Handler:      DeleteReaction,
+			Autocomplete: DeleteReactionAutocomplete,
+		},
+		&command.Command{
+			Name:        "optout_reaction",
+			Description: "turn a category or server reaction off in this channel, or back on",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "search",
+					Description: "reaction to turn off (by search)",
+					Required:    true,
+				},
+			},
+			Capability: permission.DeleteAny,
+			Handler:    OptOutReaction,
+		},
+	)
+
+	command.RegisterComponent("list_reactions", ListPages, ListReactionsPage)
+}
@@ -1,51 +1,84 @@
 package reaction
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"regexp"
-	"strings"
 	"sync"
 
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+	"rawrippers.com/grumpy-daemon/store"
 	"rawrippers.com/grumpy-daemon/utils"
 )
 
 type reaction struct {
-	EmojiID   string
-	Search    string
-	ChannelId string
+	EmojiID string
+	Search  string
+	utils.Scope
+	// AuthorId is who set the reaction. Reactions set before it was
+	// recorded have none.
+	AuthorId string `json:",omitempty"`
 }
 
 var (
 	mu        sync.Mutex
 	reactions []*reaction
+	repo      store.Repository[*reaction]
+	matcher   = utils.NewMatcher[*reaction]()
 )
 
-func Load() {
-	read()
+var customEmoji = regexp.MustCompile("<(:.+:[0-9]+)>")
+
+func Load(st *store.Store) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loading := store.Collection[*reaction](st, "reactions")
+
+	loaded, err := loading.Load()
+	if err != nil {
+		return fmt.Errorf("could not load reactions: %w", err)
+	}
+	repo = loading
+
+	reactions = loaded
+	log.Printf("loaded %d reactions", len(reactions))
+
+	triggers := make([]utils.Trigger[*reaction], 0, len(reactions))
+	for _, r := range reactions {
+		triggers = append(triggers, r.trigger())
+	}
+	matcher.Build(triggers)
+
+	return nil
 }
 
-func SetReaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func SetReaction(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: set(s, i),
+			Content: set(s, i, args),
 		},
 	})
 }
 
-func ListReactions(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// ListPages is the custom id prefix of the /list_reactions page buttons.
+const ListPages = "list_reactions"
+
+func ListReactions(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: list(i),
-		},
+		Data: list(s, i).Page(0),
 	})
 }
 
+// ListReactionsPage shows another page of /list_reactions.
+func ListReactionsPage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	list(s, i).Turn(s, i, utils.PageOf(i))
+}
+
 func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if m.Author.ID == s.State.User.ID {
 		return
@@ -55,194 +88,219 @@ func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 
+	var matched []*reaction
+	for _, key := range utils.Locate(s, m.GuildID, m.ChannelID).Keys() {
+		matched = append(matched, matcher.Match(key, m.Content)...)
+	}
+
+	if len(matched) == 0 {
+		return
+	}
+
 	mu.Lock()
-	for _, r := range reactions {
-		if r.ChannelId == m.ChannelID && utils.ContainsSearch(strings.ToLower(m.Content), strings.ToLower(r.Search)) {
-			reg, _ := regexp.Compile("<(:.+:[0-9]+)>")
-			customEmojis := reg.FindAllString(r.EmojiID, -1)
-			for _, customEmoji := range customEmojis {
-				submatches := reg.FindStringSubmatch(customEmoji)
-				if len(submatches) == 2 {
-					s.MessageReactionAdd(m.ChannelID, m.Reference().MessageID, submatches[1])
-				}
-			}
-			otherEmojis := reg.ReplaceAllString(r.EmojiID, "")
-			s.MessageReactionAdd(m.ChannelID, m.Reference().MessageID, otherEmojis)
+	var firing []*reaction
+	for _, r := range matched {
+		if !r.OptedOut(m.ChannelID) {
+			firing = append(firing, r)
 		}
 	}
 	mu.Unlock()
+
+	for _, r := range firing {
+		customEmojis := customEmoji.FindAllStringSubmatch(r.EmojiID, -1)
+		for _, submatches := range customEmojis {
+			s.MessageReactionAdd(m.ChannelID, m.Reference().MessageID, submatches[1])
+		}
+		otherEmojis := customEmoji.ReplaceAllString(r.EmojiID, "")
+		s.MessageReactionAdd(m.ChannelID, m.Reference().MessageID, otherEmojis)
+	}
 }
 
-func DeleteReaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func DeleteReaction(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: delete(s, i),
+			Content: remove(s, i, args),
 		},
 	})
 }
 
-func delete(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
-	}
-
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
-	}
+// DeleteReactionAutocomplete suggests the searches of the reactions that apply
+// in the channel for /delete_reaction.
+func DeleteReactionAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	utils.Suggest(s, i, args.String(args.Focused), choices(utils.Locate(s, i.GuildID, i.ChannelID)))
+}
 
-	options := i.ApplicationCommandData().Options
+// choices lists each search once, even when it is set at more than one
+// scope, since deleting it deletes them all.
+func choices(where utils.Where) []utils.Choice {
+	mu.Lock()
+	defer mu.Unlock()
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
+	var choices []utils.Choice
+	seen := make(map[string]bool)
+	for _, r := range reactions {
+		if !r.AppliesIn(where) || seen[r.Search] {
+			continue
+		}
+		seen[r.Search] = true
+		choices = append(choices, utils.Choice{Name: r.Search, Value: r.Search})
 	}
 
-	var search string
+	return choices
+}
 
-	if option, ok := optionMap["search"]; ok {
-		search = option.StringValue()
-	} else {
-		return "search is required."
-	}
+func remove(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	search := args.String("search")
+
+	where := utils.Locate(s, i.GuildID, i.ChannelID)
 
-	deleted := false
+	var deleted []*reaction
 	mu.Lock()
-	for index, r := range reactions {
-		if r.ChannelId == i.ChannelID && r.Search == search {
-			reactions[index] = reactions[len(reactions)-1]
-			reactions = reactions[:len(reactions)-1]
-			deleted = true
+	for _, r := range reactions {
+		if r.AppliesIn(where) && r.Search == search && !permission.MayDelete(i, r.AuthorId, "deleting reaction "+search) {
+			mu.Unlock()
+			return fmt.Sprintf("Reaction `%s` isn't yours, you need `%s` to delete it.", search, permission.DeleteAny)
+		}
+	}
+	kept := reactions[:0]
+	for _, r := range reactions {
+		if r.AppliesIn(where) && r.Search == search {
+			deleted = append(deleted, r)
+			continue
 		}
+		kept = append(kept, r)
+	}
+	reactions = kept
+	for _, r := range deleted {
+		index(r.Key())
+	}
+	if len(deleted) > 0 {
+		write()
 	}
 	mu.Unlock()
 
-	if deleted {
-		return fmt.Sprintf("<@%s> deleted reaction `%s`.", i.Member.User.ID, search)
+	if len(deleted) > 0 {
+		return fmt.Sprintf("<@%s> deleted reaction `%s`.", args.User.ID, search)
 	} else {
 		return fmt.Sprintf("Could not find reaction `%s` to delete.", search)
 	}
 }
 
-func list(i *discordgo.InteractionCreate) string {
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
-	}
+func OptOutReaction(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: optOut(s, i, args),
+		},
+	})
+}
 
-	reactionsResp := ""
+// optOut switches a category or guild reaction off in this channel, or back
+// on if it already was.
+func optOut(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	search := args.String("search")
 
-	mu.Lock()
+	where := utils.Locate(s, i.GuildID, i.ChannelID)
 
+	var off, on int
+	mu.Lock()
 	for _, r := range reactions {
-		if r.ChannelId == i.ChannelID {
-			reactionsResp = fmt.Sprintf("%s\n%s:\t\t%s", reactionsResp, r.Search, r.EmojiID)
+		if r.Search != search || !r.AppliesIn(where) || r.Name() == utils.ScopeChannel {
+			continue
+		}
+		if r.ToggleOptOut(i.ChannelID) {
+			off++
+		} else {
+			on++
 		}
 	}
-
+	if off+on > 0 {
+		write()
+	}
 	mu.Unlock()
 
-	if len(reactionsResp) == 0 {
-		return "no reactions"
+	switch {
+	case off > 0:
+		return fmt.Sprintf("<@%s> turned reaction `%s` off in this channel.", args.User.ID, search)
+	case on > 0:
+		return fmt.Sprintf("<@%s> turned reaction `%s` back on in this channel.", args.User.ID, search)
 	}
 
-	return fmt.Sprintf("```%s```", reactionsResp)
+	return fmt.Sprintf("Could not find a category or server reaction `%s` here. Use /delete_reaction for channel reactions.", search)
 }
 
-func set(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
-	}
+func list(s *discordgo.Session, i *discordgo.InteractionCreate) utils.List {
+	l := utils.List{Prefix: ListPages, Title: "Reactions", Empty: "no reactions", Code: true}
 
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
-	}
+	where := utils.Locate(s, i.GuildID, i.ChannelID)
 
-	options := i.ApplicationCommandData().Options
+	mu.Lock()
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
+	for _, r := range reactions {
+		if r.AppliesIn(where) {
+			line := fmt.Sprintf("%s:\t%s\t%s", r.Search, r.Name(), r.EmojiID)
+			if r.OptedOut(i.ChannelID) {
+				line += "\t(off here)"
+			}
+			l.Lines = append(l.Lines, line)
+		}
 	}
 
-	var emojiID string
-	var search string
+	mu.Unlock()
+
+	return l
+}
 
-	if option, ok := optionMap["emoji"]; ok {
-		emojiID = option.StringValue()
-	} else {
-		return "Message is required."
+func set(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	emojiID := args.String("emoji")
+	search := args.String("search")
+	level := utils.ScopeChannel
+
+	if args.Has("scope") {
+		level = args.String("scope")
 	}
 
-	if option, ok := optionMap["search"]; ok {
-		search = option.StringValue()
-	} else {
-		return "Search is required."
+	scope, err := utils.NewScope(s, level, i.GuildID, i.ChannelID)
+	if err != nil {
+		return fmt.Sprintf("I can't set a %s reaction here: %s.", level, err)
 	}
 
 	r := reaction{
-		EmojiID:   emojiID,
-		Search:    search,
-		ChannelId: i.ChannelID,
+		EmojiID:  emojiID,
+		Search:   search,
+		Scope:    scope,
+		AuthorId: args.User.ID,
 	}
 
 	mu.Lock()
 	reactions = append(reactions, &r)
+	index(r.Key())
 	write()
 	mu.Unlock()
 
-	return fmt.Sprintf("<@%s> set a reaction `%s` to `%s`. Use /list_reactions to see reactions.", i.Member.User.ID, emojiID, search)
+	return fmt.Sprintf("<@%s> set a %s reaction `%s` to `%s`. Use /list_reactions to see reactions.", args.User.ID, r.Name(), emojiID, search)
 }
 
-func write() {
-	createDirs()
-	homedir := homeDir()
-	file, err := json.MarshalIndent(&reactions, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = os.WriteFile(fmt.Sprintf("%s/.grumpy/reactions/reactions.json", homedir), file, 0644)
-
-	if err != nil {
-		log.Fatal(err)
-	}
+func (r *reaction) trigger() utils.Trigger[*reaction] {
+	return utils.Trigger[*reaction]{Key: r.Key(), Keyword: r.Search, WholeWord: true, Value: r}
 }
 
-func read() {
-	createDirs()
-	homedir := homeDir()
-	file, err := os.ReadFile(fmt.Sprintf("%s/.grumpy/reactions/reactions.json", homedir))
-
-	if err != nil {
-		log.Printf("Could not open reactions: %s", err)
-		return
-	}
-
-	err = json.Unmarshal(file, &reactions)
-
-	log.Printf("loaded %d reactions", len(reactions))
-
-	if err != nil {
-		log.Fatal(err)
+// index rebuilds the triggers for one key after its reactions changed. Call
+// it with mu held.
+func index(key string) {
+	triggers := make([]utils.Trigger[*reaction], 0, len(reactions))
+	for _, r := range reactions {
+		if r.Key() == key {
+			triggers = append(triggers, r.trigger())
+		}
 	}
-}
 
-func homeDir() string {
-	homedir, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatal(err)
-	}
-	return homedir
+	matcher.Update(key, triggers)
 }
 
-func createDirs() {
-	homedir := homeDir()
-
-	path := fmt.Sprintf("%s/.grumpy/reactions/", homedir)
-	err := os.MkdirAll(path, os.ModePerm)
-
-	if err != nil {
-		log.Fatal(err)
+func write() {
+	if err := repo.Save(reactions); err != nil {
+		log.Printf("could not save reactions: %s", err)
 	}
 }
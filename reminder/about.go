@@ -0,0 +1,197 @@
+package reminder
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/timezone"
+)
+
+// AboutModal is the custom id prefix of the "remind me about this message"
+// modal. The id of the message follows the colon.
+const AboutModal = "remind_about"
+
+// maxQuote keeps a quoted message well inside Discord's 2000 character limit.
+const maxQuote = 1500
+
+// quoteExpiry is how long a message is held for a modal that may never be
+// submitted.
+const quoteExpiry = time.Hour
+
+var (
+	quotesMu sync.Mutex
+	// quotes holds the messages reminders are being set about, by user and
+	// message id. The menu command carries the message, the modal submit
+	// doesn't.
+	quotes = make(map[string]heldQuote)
+)
+
+type heldQuote struct {
+	content string
+	at      time.Time
+}
+
+// RemindAboutMessage answers the message context menu command by asking when
+// to send the reminder.
+func RemindAboutMessage(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	if resolved := i.ApplicationCommandData().Resolved; resolved != nil {
+		if message, ok := resolved.Messages[args.Target]; ok {
+			holdQuote(args.User.ID, args.Target, message.Content, time.Now())
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: fmt.Sprintf("%s:%s", AboutModal, args.Target),
+			Title:    "Remind me about this",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "when",
+							Label:       "When",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "in 2 hours, tomorrow at noon, friday 9am",
+							Required:    true,
+							MaxLength:   100,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "note",
+							Label:     "Note",
+							Style:     discordgo.TextInputShort,
+							Required:  false,
+							MaxLength: 200,
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+// RemindAboutMessageSubmit creates the reminder once the modal is filled in.
+func RemindAboutMessageSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: about(i),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func about(i *discordgo.InteractionCreate) string {
+	user := command.User(i)
+	data := i.ModalSubmitData()
+
+	_, messageId, ok := strings.Cut(data.CustomID, ":")
+	if !ok || len(messageId) == 0 {
+		return "Which message?"
+	}
+
+	inputs := modalInputs(data)
+
+	e, err := buildEvent("", inputs["when"], i.ChannelID, time.Now(), timezone.Location(user.ID))
+	if err != nil {
+		return fmt.Sprintf("I didn't understand that (%s). Try `in 2 hours`, `tomorrow at noon` or `friday 9am`.", err)
+	}
+
+	e.Message = inputs["note"]
+	if len(e.Message) == 0 {
+		e.Message = "You asked me to remind you about this:"
+	}
+	e.AuthorId = user.ID
+	e.Ping = []string{fmt.Sprintf("<@%s>", user.ID)}
+	e.Quote = takeQuote(user.ID, messageId)
+
+	guildId := i.GuildID
+	if len(guildId) == 0 {
+		guildId = "@me"
+	}
+	e.Link = fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildId, i.ChannelID, messageId)
+
+	mu.Lock()
+	e.Id = newId()
+	heap.Push(&events, e)
+	write()
+	mu.Unlock()
+
+	notify()
+
+	return fmt.Sprintf("I'll remind you about that message at <t:%d:F>. It's reminder `%s` in /list_reminders.", e.Next.Unix(), e.Id)
+}
+
+// holdQuote keeps content until the modal for messageId is submitted,
+// dropping what was held for modals long since abandoned.
+func holdQuote(userId string, messageId string, content string, now time.Time) {
+	quotesMu.Lock()
+	defer quotesMu.Unlock()
+
+	for key, held := range quotes {
+		if now.Sub(held.at) > quoteExpiry {
+			delete(quotes, key)
+		}
+	}
+
+	quotes[userId+":"+messageId] = heldQuote{content: content, at: now}
+}
+
+// takeQuote returns what holdQuote kept for messageId, or "" if nothing was.
+func takeQuote(userId string, messageId string) string {
+	quotesMu.Lock()
+	defer quotesMu.Unlock()
+
+	key := userId + ":" + messageId
+	held := quotes[key]
+	delete(quotes, key)
+	return held.content
+}
+
+// modalInputs collects the values of a modal's text inputs by custom id.
+func modalInputs(data discordgo.ModalSubmitInteractionData) map[string]string {
+	inputs := make(map[string]string)
+
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, c := range row.Components {
+			if input, ok := c.(*discordgo.TextInput); ok {
+				inputs[input.CustomID] = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+
+	return inputs
+}
+
+// text is what gets posted when e fires: its message, followed by the quoted
+// message and a link back to it for reminders about a message.
+func (e *event) text() string {
+	text := e.Message
+
+	if len(e.Quote) > 0 {
+		quote := e.Quote
+		if len(quote) > maxQuote {
+			quote = strings.ToValidUTF8(quote[:maxQuote], "") + "…"
+		}
+		text = fmt.Sprintf("%s\n> %s", text, strings.ReplaceAll(quote, "\n", "\n> "))
+	}
+
+	if len(e.Link) > 0 {
+		text = fmt.Sprintf("%s\n%s", text, e.Link)
+	}
+
+	return text
+}
@@ -0,0 +1,137 @@
+package reminder
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/timezone"
+	"rawrippers.com/grumpy-daemon/utils"
+)
+
+// maxCalendarSize limits how much of an uploaded .ics file is read.
+const maxCalendarSize = 1 << 20
+
+func ExportReminders(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	content, file := export(i)
+
+	data := &discordgo.InteractionResponseData{
+		Content: content,
+	}
+
+	if file != nil {
+		data.Files = []*discordgo.File{
+			{
+				Name:        "reminders.ics",
+				ContentType: "text/calendar",
+				Reader:      bytes.NewReader(file),
+			},
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+}
+
+func export(i *discordgo.InteractionCreate) (string, []byte) {
+	var channelEvents []*event
+
+	mu.Lock()
+	for _, e := range events {
+		if e.ChannelId == i.ChannelID {
+			channelEvents = append(channelEvents, e)
+		}
+	}
+	file := exportICS(channelEvents, time.Now())
+	mu.Unlock()
+
+	if len(channelEvents) == 0 {
+		return "no upcoming events", nil
+	}
+
+	return fmt.Sprintf("Here are this channel's %d reminders.", len(channelEvents)), file
+}
+
+func ImportReminders(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	content := importCalendar(s, i, args)
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+}
+
+func importCalendar(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	attachment := args.Attachment("file")
+	if attachment == nil {
+		return "I can't find that file."
+	}
+
+	if attachment.Size > maxCalendarSize {
+		return "That file is too big."
+	}
+
+	file, err := utils.Download(attachment.URL, maxCalendarSize)
+	if err != nil {
+		log.Print(err)
+		return "I couldn't download that file."
+	}
+
+	imported, notes, err := importICS(file, i.ChannelID, time.Now(), timezone.Location(args.User.ID))
+	if err != nil {
+		return fmt.Sprintf("That doesn't look like a calendar file (%s).", err)
+	}
+
+	// Pings were allowed for whoever exported the reminders, so check them
+	// again for whoever imports them here.
+	var perms int64
+	if i.Member != nil {
+		perms = i.Member.Permissions
+	}
+	allowed := imported[:0]
+	for _, e := range imported {
+		err := checkPing(e.Ping, perms, func(id string) (*discordgo.Role, error) {
+			return guildRole(s, i.GuildID, id)
+		})
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("skipped `%s`: %s", e.Message, err))
+			continue
+		}
+		allowed = append(allowed, e)
+	}
+	imported = allowed
+
+	mu.Lock()
+	for _, e := range imported {
+		e.Id = newId()
+		e.AuthorId = args.User.ID
+		heap.Push(&events, e)
+	}
+	if len(imported) > 0 {
+		write()
+	}
+	mu.Unlock()
+
+	notify()
+
+	reply := fmt.Sprintf("<@%s> imported %d reminders. Use /list_reminders to see reminders.", args.User.ID, len(imported))
+	if len(notes) > 0 {
+		reply = fmt.Sprintf("%s\n%s", reply, strings.Join(notes, "\n"))
+	}
+
+	if len(reply) > 2000 {
+		reply = strings.ToValidUTF8(reply[:1997], "") + "..."
+	}
+
+	return reply
+}
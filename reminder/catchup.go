@@ -0,0 +1,216 @@
+package reminder
+
+import (
+	"fmt"
+	"time"
+)
+
+// CatchUp decides what happens to reminders that came due while the daemon
+// was down.
+type CatchUp struct {
+	// Mode is "late" to fire overdue reminders with a note saying how late
+	// they are, "skip" to also drop the ones older than MaxAge, or "summary"
+	// to collapse them into one message per channel.
+	Mode   string
+	MaxAge time.Duration
+	// Replay fires every missed occurrence of a recurring reminder instead
+	// of only the most recent one.
+	Replay bool
+}
+
+const (
+	CatchUpLate    = "late"
+	CatchUpSkip    = "skip"
+	CatchUpSummary = "summary"
+)
+
+// lateAfter is how far past due a reminder has to be before it counts as
+// missed rather than merely delayed.
+const lateAfter = time.Minute
+
+// maxReplay bounds how many missed occurrences of one recurring reminder are
+// replayed, so an "every minute" rule doesn't flood a channel after a long
+// outage.
+const maxReplay = 50
+
+var catchUp = CatchUp{Mode: CatchUpLate}
+
+func SetCatchUp(c CatchUp) error {
+	if err := c.Check(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	catchUp = c
+	mu.Unlock()
+
+	return nil
+}
+
+// Check reports whether c is a policy SetCatchUp accepts.
+func (c CatchUp) Check() error {
+	switch c.Mode {
+	case CatchUpLate, CatchUpSummary:
+	case CatchUpSkip:
+		if c.MaxAge <= 0 {
+			return fmt.Errorf("catch up mode %q needs a max age", c.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown catch up mode %q", c.Mode)
+	}
+	return nil
+}
+
+type occurrence struct {
+	event
+	at time.Time
+}
+
+type delivery struct {
+	channelId string
+	// dmUserId sends the message to this user's DMs instead of channelId.
+	dmUserId string
+	ping     []string
+	message  string
+}
+
+// destination identifies where a delivery ends up, for grouping summaries.
+func (d delivery) destination() string {
+	if len(d.dmUserId) > 0 {
+		return "dm:" + d.dmUserId
+	}
+	return d.channelId
+}
+
+// occurrences lists the times e should fire for, up to now. One-off events
+// and recurring events without replay fire once, for their latest missed
+// occurrence.
+func (e *event) occurrences(now time.Time, replay bool) []time.Time {
+	sched, next := e.recurrence()
+	if sched == nil {
+		return []time.Time{e.Next}
+	}
+
+	keep := 1
+	if replay {
+		keep = maxReplay
+	}
+
+	// Step from shortly before now rather than from next, which can be a
+	// long way back after an outage, looking further back until enough
+	// occurrences turn up.
+	var times []time.Time
+	for back := lateAfter; ; back *= 2 {
+		from := sched.Skip(next, now.Add(-back))
+
+		times = nil
+		for t := from; !t.IsZero() && !t.After(now); t = sched.Next(t) {
+			times = append(times, t)
+			if len(times) > keep {
+				times = times[1:]
+			}
+		}
+
+		if len(times) == keep || from.Equal(next) {
+			break
+		}
+	}
+
+	if len(times) == 0 {
+		return []time.Time{e.Next}
+	}
+
+	if !replay {
+		return times[len(times)-1:]
+	}
+
+	return times
+}
+
+// deliveries turns occurrences into the messages to send, applying the
+// policy to the ones that are late.
+func (c CatchUp) deliveries(due []occurrence, now time.Time) []delivery {
+	var out []delivery
+	var missed []string
+	summaries := make(map[string]*delivery)
+
+	for _, o := range due {
+		d := o.delivery()
+		late := now.Sub(o.at)
+
+		if late <= lateAfter {
+			out = append(out, d)
+			continue
+		}
+
+		switch c.Mode {
+		case CatchUpSkip:
+			if late > c.MaxAge {
+				continue
+			}
+		case CatchUpSummary:
+			summary, ok := summaries[d.destination()]
+			if !ok {
+				summary = &delivery{channelId: d.channelId, dmUserId: d.dmUserId, message: "While I was away I missed these reminders:"}
+				summaries[d.destination()] = summary
+				missed = append(missed, d.destination())
+			}
+			summary.ping = mergePing(summary.ping, d.ping)
+			summary.message += fmt.Sprintf("\n- %s (due <t:%d:F>)", o.Message, o.at.Unix())
+			if len(o.Link) > 0 {
+				summary.message += " " + o.Link
+			}
+			continue
+		}
+
+		d.message = fmt.Sprintf("%s (late by %s)", d.message, lateBy(late))
+		out = append(out, d)
+	}
+
+	for _, destination := range missed {
+		out = append(out, *summaries[destination])
+	}
+
+	return out
+}
+
+func (o occurrence) delivery() delivery {
+	d := delivery{channelId: o.ChannelId, ping: o.Ping, message: o.text()}
+	if o.Dm {
+		d.dmUserId = o.AuthorId
+	}
+	return d
+}
+
+func mergePing(ping []string, more []string) []string {
+	for _, m := range more {
+		found := false
+		for _, p := range ping {
+			if p == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ping = append(ping, m)
+		}
+	}
+	return ping
+}
+
+// lateBy rounds a duration to something readable like "3h" or "2d 4h".
+func lateBy(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if hours == 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
@@ -0,0 +1,201 @@
+package reminder
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withCatchUp(t *testing.T, c CatchUp) {
+	if err := SetCatchUp(c); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetCatchUp(CatchUp{Mode: CatchUpLate}) })
+}
+
+func (h *harness) collect(t *testing.T, n int) []string {
+	t.Helper()
+
+	var messages []string
+	for len(messages) < n {
+		select {
+		case d := <-h.fired:
+			messages = append(messages, d.channelId+": "+d.message)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d messages, want %d: %q", len(messages), n, messages)
+		}
+	}
+
+	h.expectQuiet(t)
+	sort.Strings(messages)
+
+	return messages
+}
+
+func expectMessages(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got messages:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCatchUpLate(t *testing.T) {
+	withCatchUp(t, CatchUp{Mode: CatchUpLate})
+
+	now := date("2022-10-26 12:00")
+	h := startScheduler(t, now,
+		&event{Message: "lunch", Next: date("2022-10-26 09:00"), ChannelId: "1"},
+		&event{Message: "on time", Next: now.Add(-10 * time.Second), ChannelId: "1"},
+		&event{Message: "yesterday", Next: date("2022-10-25 08:00"), ChannelId: "2"},
+	)
+
+	<-h.saves
+	expectMessages(t, h.collect(t, 3),
+		"1: lunch (late by 3h)",
+		"1: on time",
+		"2: yesterday (late by 1d 4h)",
+	)
+}
+
+func TestCatchUpSkip(t *testing.T) {
+	withCatchUp(t, CatchUp{Mode: CatchUpSkip, MaxAge: 6 * time.Hour})
+
+	now := date("2022-10-26 12:00")
+	h := startScheduler(t, now,
+		&event{Message: "lunch", Next: date("2022-10-26 09:00"), ChannelId: "1"},
+		&event{Message: "yesterday", Next: date("2022-10-25 08:00"), ChannelId: "2"},
+	)
+
+	<-h.saves
+	expectMessages(t, h.collect(t, 1), "1: lunch (late by 3h)")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 0 {
+		t.Errorf("skipped reminders were kept: %d left", len(events))
+	}
+}
+
+func TestCatchUpSummary(t *testing.T) {
+	withCatchUp(t, CatchUp{Mode: CatchUpSummary})
+
+	now := date("2022-10-26 12:00")
+	h := startScheduler(t, now,
+		&event{Message: "lunch", Next: date("2022-10-26 09:00"), ChannelId: "1"},
+		&event{Message: "coffee", Next: date("2022-10-26 10:00"), ChannelId: "1"},
+		&event{Message: "on time", Next: now, ChannelId: "1"},
+		&event{Message: "yesterday", Next: date("2022-10-25 08:00"), ChannelId: "2"},
+	)
+
+	<-h.saves
+	expectMessages(t, h.collect(t, 3),
+		"1: on time",
+		"1: While I was away I missed these reminders:\n- lunch (due <t:1666774800:F>)\n- coffee (due <t:1666778400:F>)",
+		"2: While I was away I missed these reminders:\n- yesterday (due <t:1666684800:F>)",
+	)
+}
+
+func TestCatchUpRecurring(t *testing.T) {
+	now := date("2022-10-26 12:00")
+	standup := func() *event {
+		return &event{Message: "standup", Next: date("2022-10-24 09:00"), ChannelId: "1", Rule: "every day at 9am"}
+	}
+
+	t.Run("latest only", func(t *testing.T) {
+		withCatchUp(t, CatchUp{Mode: CatchUpLate})
+		h := startScheduler(t, now, standup())
+
+		<-h.saves
+		expectMessages(t, h.collect(t, 1), "1: standup (late by 3h)")
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		withCatchUp(t, CatchUp{Mode: CatchUpLate, Replay: true})
+		h := startScheduler(t, now, standup())
+
+		<-h.saves
+		expectMessages(t, h.collect(t, 3),
+			"1: standup (late by 2d 3h)",
+			"1: standup (late by 1d 3h)",
+			"1: standup (late by 3h)",
+		)
+	})
+
+	t.Run("replay skips old occurrences", func(t *testing.T) {
+		withCatchUp(t, CatchUp{Mode: CatchUpSkip, MaxAge: 36 * time.Hour, Replay: true})
+		h := startScheduler(t, now, standup())
+
+		<-h.saves
+		expectMessages(t, h.collect(t, 2),
+			"1: standup (late by 1d 3h)",
+			"1: standup (late by 3h)",
+		)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(events) != 1 || !events[0].Next.Equal(date("2022-10-27 09:00")) {
+			t.Errorf("recurring reminder was not rescheduled: %+v", events)
+		}
+	})
+}
+
+// countingSchedule counts the steps taken through a schedule.
+type countingSchedule struct {
+	schedule
+	steps int
+}
+
+func (c *countingSchedule) Next(prev time.Time) time.Time {
+	c.steps++
+	return c.schedule.Next(prev)
+}
+
+func TestCatchUpLongOutage(t *testing.T) {
+	now := date("2022-10-26 12:03")
+
+	for _, rule := range []string{"* * * * *", "every 7 minutes", "every 5 hours", "*/10 9-17 * * mon-fri", "every weekday at 9am"} {
+		e := &event{Message: "tick", Next: date("2021-10-26 12:00"), Rule: rule}
+
+		// what stepping through the whole year gives
+		sched, next := e.recurrence()
+		var stepped []time.Time
+		for ; !next.After(now); next = sched.Next(next) {
+			stepped = append(stepped, next)
+		}
+
+		counted := &countingSchedule{schedule: sched}
+		e.sched = counted
+		replayed := e.occurrences(now, true)
+		latest := e.occurrences(now, false)
+		if !e.reschedule(now) {
+			t.Fatalf("%q: recurring event was not kept", rule)
+		}
+		if counted.steps > 5000 {
+			t.Errorf("%q: catching up on a year took %d steps", rule, counted.steps)
+		}
+
+		want := stepped[len(stepped)-maxReplay:]
+		if len(replayed) != maxReplay || !replayed[0].Equal(want[0]) || !replayed[maxReplay-1].Equal(want[maxReplay-1]) {
+			t.Errorf("%q: replayed %d occurrences from %s, want %d from %s", rule, len(replayed), replayed[0], maxReplay, want[0])
+		}
+		if len(latest) != 1 || !latest[0].Equal(stepped[len(stepped)-1]) {
+			t.Errorf("%q: latest occurrence %v, want %s", rule, latest, stepped[len(stepped)-1])
+		}
+		if !e.Next.Equal(next) {
+			t.Errorf("%q: rescheduled to %s, want %s", rule, e.Next, next)
+		}
+	}
+}
+
+func TestSetCatchUpValidates(t *testing.T) {
+	if err := SetCatchUp(CatchUp{Mode: "sometimes"}); err == nil {
+		t.Error("unknown mode was accepted")
+	}
+
+	if err := SetCatchUp(CatchUp{Mode: CatchUpSkip}); err == nil {
+		t.Error("skip without a max age was accepted")
+	}
+}
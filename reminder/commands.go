@@ -0,0 +1,159 @@
+package reminder
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+)
+
+func init() {
+	command.Register(
+		&command.Command{
+			Name:        "reminder",
+			Description: "set a channel reminder",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "message",
+					Description: "message to post",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "when",
+					Description: "e.g. in 20 minutes, tomorrow at noon, next tuesday 9am, 2022-10-29 08:43 -0400",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "repeat",
+					Description: "e.g. every weekday at 9am, every 2 weeks on Friday, 0 9 1 * *",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "dm",
+					Description: "send the reminder to your DMs",
+					Required:    false,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "post in another channel",
+					Required:     false,
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "ping",
+					Description: "roles or users to ping, e.g. @devs @someone",
+					Required:    false,
+				},
+			},
+			Capability: permission.Create,
+			Handler:    SetReminder,
+		},
+		&command.Command{
+			Name:        "list_reminders",
+			Description: "list all channel reminders",
+			Handler:     ListReminders,
+		},
+		&command.Command{
+			Name:        "delete_reminder",
+			Description: "delete a reminder",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "reminder",
+					Description:  "id from /list_reminders, or the reminder's message",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+			Capability:   permission.DeleteOwn,
+			Handler:      DeleteReminder,
+			Autocomplete: DeleteReminderAutocomplete,
+		},
+		&command.Command{
+			Name:        "edit_reminder",
+			Description: "change a reminder",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "id",
+					Description: "id from /list_reminders",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "message",
+					Description: "new message to post",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "when",
+					Description: "new time, e.g. tomorrow at noon",
+					Required:    false,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "channel to post in",
+					Required:     false,
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+			},
+			Capability: permission.DeleteOwn,
+			Handler:    EditReminder,
+		},
+		&command.Command{
+			Name:        "snooze_reminder",
+			Description: "push a reminder back",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "id",
+					Description: "id from /list_reminders",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "e.g. 10m, 2 hours, 1d",
+					Required:    true,
+				},
+			},
+			Capability: permission.DeleteOwn,
+			Handler:    SnoozeReminder,
+		},
+		&command.Command{
+			Name:       "Remind me about this",
+			Type:       discordgo.MessageApplicationCommand,
+			Capability: permission.Create,
+			Handler:    RemindAboutMessage,
+		},
+		&command.Command{
+			Name:        "export_reminders",
+			Description: "download the channel's reminders as an .ics calendar",
+			Handler:     ExportReminders,
+		},
+		&command.Command{
+			Name:        "import_reminders",
+			Description: "add reminders from an .ics calendar",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: ".ics file",
+					Required:    true,
+				},
+			},
+			Capability: permission.Create,
+			Handler:    ImportReminders,
+		},
+	)
+
+	command.RegisterModal("Remind me about this", AboutModal, RemindAboutMessageSubmit)
+	command.RegisterComponent("list_reminders", ListPages, ListRemindersPage)
+}
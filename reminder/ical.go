@@ -0,0 +1,690 @@
+package reminder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file reads and writes RFC 5545 iCalendar files. Exported reminders
+// carry their original rule, quote, pings and whether they go to DMs in
+// X-GRUMPY- properties so they come back unchanged; other calendars are
+// imported through their RRULE and VALARMs.
+
+const (
+	icsDateTime    = "20060102T150405"
+	icsDateTimeUTC = "20060102T150405Z"
+	icsDate        = "20060102"
+	icsLineLength  = 75
+	maxImport      = 100
+)
+
+type icsProp struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+type icsComponent struct {
+	name     string
+	props    []icsProp
+	children []*icsComponent
+}
+
+func (c *icsComponent) get(name string) *icsProp {
+	for index := range c.props {
+		if c.props[index].name == name {
+			return &c.props[index]
+		}
+	}
+	return nil
+}
+
+func (c *icsComponent) value(name string) string {
+	if prop := c.get(name); prop != nil {
+		return prop.value
+	}
+	return ""
+}
+
+// export
+
+// exportICS writes events as a calendar, one VEVENT with a VALARM each.
+func exportICS(events []*event, now time.Time) []byte {
+	var b bytes.Buffer
+
+	line := func(s string) {
+		b.WriteString(foldLine(s))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//grumpy-daemon//reminders//EN")
+	line("CALSCALE:GREGORIAN")
+
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:%s@grumpy-daemon", e.Id))
+		line("DTSTAMP:" + now.UTC().Format(icsDateTimeUTC))
+		line("DTSTART" + icsTime(e.Next, e.Zone))
+		line("SUMMARY:" + escapeText(e.Message))
+
+		if description := strings.TrimPrefix(e.text(), e.Message); len(description) > 0 {
+			line("DESCRIPTION:" + escapeText(strings.TrimPrefix(description, "\n")))
+		}
+
+		if len(e.Link) > 0 {
+			line("URL:" + e.Link)
+		}
+
+		if len(e.Quote) > 0 {
+			line("X-GRUMPY-QUOTE:" + escapeText(e.Quote))
+		}
+		if len(e.Ping) > 0 {
+			line("X-GRUMPY-PING:" + escapeText(strings.Join(e.Ping, " ")))
+		}
+		if e.Dm {
+			line("X-GRUMPY-DM:TRUE")
+		}
+
+		if len(e.Rule) > 0 {
+			if sched, _ := e.recurrence(); sched != nil {
+				if rrule, ok := rruleFor(sched); ok {
+					line("RRULE:" + rrule)
+				}
+			}
+			line("X-GRUMPY-RULE:" + escapeText(e.Rule))
+		}
+
+		line("BEGIN:VALARM")
+		line("ACTION:DISPLAY")
+		line("TRIGGER:PT0S")
+		line("DESCRIPTION:" + escapeText(e.Message))
+		line("END:VALARM")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	return b.Bytes()
+}
+
+// icsTime formats t for a DTSTART property, with a TZID when the event has a
+// named zone so recurrences follow its daylight saving changes.
+func icsTime(t time.Time, zone string) string {
+	if len(zone) > 0 {
+		if loc, err := time.LoadLocation(zone); err == nil {
+			return fmt.Sprintf(";TZID=%s:%s", zone, t.In(loc).Format(icsDateTime))
+		}
+	}
+	return ":" + t.UTC().Format(icsDateTimeUTC)
+}
+
+var icsDays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// rruleFor translates a schedule to an RRULE. Cron expressions that restrict
+// both the day of month and the day of week can't be expressed, since cron
+// matches either one while RRULE needs both.
+func rruleFor(sched schedule) (string, bool) {
+	switch s := sched.(type) {
+	case *phraseSchedule:
+		interval := ""
+		if s.interval > 1 {
+			interval = fmt.Sprintf(";INTERVAL=%d", s.interval)
+		}
+
+		byDay := ""
+		if s.weekdays != nil {
+			var days []string
+			for _, weekday := range sortedWeekdays(s.weekdays) {
+				days = append(days, icsDays[weekday])
+			}
+			byDay = ";BYDAY=" + strings.Join(days, ",")
+		}
+
+		switch s.unit {
+		case minutes:
+			return "FREQ=MINUTELY" + interval, true
+		case hours:
+			return "FREQ=HOURLY" + interval, true
+		case days:
+			if s.weekdays != nil {
+				return "FREQ=WEEKLY" + byDay, true
+			}
+			return "FREQ=DAILY" + interval, true
+		case weeks:
+			return "FREQ=WEEKLY" + interval + byDay, true
+		case months:
+			return fmt.Sprintf("FREQ=MONTHLY%s;BYMONTHDAY=%d", interval, s.monthDay), true
+		}
+	case *cronSchedule:
+		if !s.domStar && !s.dowStar {
+			return "", false
+		}
+
+		rrule := fmt.Sprintf("FREQ=DAILY;BYHOUR=%s;BYMINUTE=%s", bitList(s.hour, 0, 23, nil), bitList(s.minute, 0, 59, nil))
+		if !s.domStar {
+			rrule += ";BYMONTHDAY=" + bitList(s.dom, 1, 31, nil)
+		}
+		if s.month != bits(1, 12) {
+			rrule += ";BYMONTH=" + bitList(s.month, 1, 12, nil)
+		}
+		if !s.dowStar {
+			rrule += ";BYDAY=" + bitList(s.dow, 0, 6, icsDays)
+		}
+		return rrule, true
+	}
+
+	return "", false
+}
+
+func bits(min int, max int) uint64 {
+	var b uint64
+	for v := min; v <= max; v++ {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+func bitList(b uint64, min int, max int, names []string) string {
+	var values []string
+	for v := min; v <= max; v++ {
+		if b&(1<<uint(v)) != 0 {
+			if names != nil {
+				values = append(values, names[v])
+			} else {
+				values = append(values, strconv.Itoa(v))
+			}
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// foldLine splits s into lines of at most 75 octets as RFC 5545 requires,
+// without cutting a UTF-8 sequence in half.
+func foldLine(s string) string {
+	var b strings.Builder
+
+	limit := icsLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = icsLineLength - 1
+	}
+
+	b.WriteString(s)
+	b.WriteString("\r\n")
+
+	return b.String()
+}
+
+// import
+
+// parseICS reads the component tree of a calendar file.
+func parseICS(data []byte) (*icsComponent, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	root := &icsComponent{}
+	stack := []*icsComponent{root}
+
+	for _, line := range lines {
+		prop, err := parseProp(line)
+		if err != nil {
+			return nil, err
+		}
+
+		current := stack[len(stack)-1]
+
+		switch prop.name {
+		case "BEGIN":
+			child := &icsComponent{name: strings.ToUpper(prop.value)}
+			current.children = append(current.children, child)
+			stack = append(stack, child)
+		case "END":
+			if len(stack) == 1 || current.name != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("unexpected END:%s", prop.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			current.props = append(current.props, prop)
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("%s is never closed", stack[len(stack)-1].name)
+	}
+
+	for _, child := range root.children {
+		if child.name == "VCALENDAR" {
+			return child, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no VCALENDAR found")
+}
+
+// parseProp splits `NAME;PARAM=value;PARAM="quoted":value`.
+func parseProp(line string) (icsProp, error) {
+	prop := icsProp{params: make(map[string]string)}
+
+	inQuote := false
+	colon := -1
+	for index, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = index
+			break
+		}
+	}
+
+	if colon < 0 {
+		return prop, fmt.Errorf("invalid line %q", line)
+	}
+
+	prop.value = line[colon+1:]
+
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return prop, nil
+}
+
+// importICS turns the VEVENTs of a calendar into events for channelId, one per
+// VALARM or one at the start if there are none. Times without a zone are taken
+// to be in loc. Along with the events it returns a note for everything it
+// skipped or simplified.
+func importICS(data []byte, channelId string, now time.Time, loc *time.Location) ([]*event, []string, error) {
+	calendar, err := parseICS(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var imported []*event
+	var notes []string
+
+	for _, component := range calendar.children {
+		if component.name != "VEVENT" {
+			continue
+		}
+
+		summary := unescapeText(component.value("SUMMARY"))
+		if len(summary) == 0 {
+			summary = "(untitled event)"
+		}
+
+		if strings.EqualFold(component.value("STATUS"), "CANCELLED") {
+			continue
+		}
+
+		events, err := importEvent(component, summary, channelId, now, loc)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("skipped `%s`: %s", summary, err))
+			continue
+		}
+
+		for _, e := range events {
+			if len(imported) == maxImport {
+				notes = append(notes, fmt.Sprintf("stopped after %d reminders", maxImport))
+				return imported, notes, nil
+			}
+			imported = append(imported, e)
+		}
+	}
+
+	return imported, notes, nil
+}
+
+func importEvent(component *icsComponent, summary string, channelId string, now time.Time, loc *time.Location) ([]*event, error) {
+	startProp := component.get("DTSTART")
+	if startProp == nil {
+		return nil, fmt.Errorf("no start time")
+	}
+
+	start, err := parseICSTime(*startProp, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	end := start
+	if endProp := component.get("DTEND"); endProp != nil {
+		if end, err = parseICSTime(*endProp, loc); err != nil {
+			return nil, err
+		}
+	} else if duration := component.value("DURATION"); len(duration) > 0 {
+		d, err := parseICSDuration(duration)
+		if err != nil {
+			return nil, err
+		}
+		end = start.Add(d)
+	}
+
+	rule := unescapeText(component.value("X-GRUMPY-RULE"))
+	rrule := component.value("RRULE")
+
+	var ping []string
+	if value := unescapeText(component.value("X-GRUMPY-PING")); len(value) > 0 {
+		if ping, err = parsePing(value); err != nil {
+			return nil, err
+		}
+	}
+
+	// each alarm becomes a reminder, the event itself only without alarms
+	// Rules following the server's own zone are exported with a UTC
+	// start, so bring them back in whichever zone is local here.
+	local := len(rule) > 0 && len(startProp.params["TZID"]) == 0
+
+	var triggers []time.Time
+	for _, alarm := range component.children {
+		if alarm.name != "VALARM" {
+			continue
+		}
+
+		trigger := alarm.get("TRIGGER")
+		if trigger == nil {
+			continue
+		}
+
+		at, err := alarmTime(*trigger, start, end, loc)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, at)
+	}
+
+	if len(triggers) == 0 {
+		triggers = []time.Time{start}
+	}
+
+	var events []*event
+	for _, at := range triggers {
+		if local {
+			at = at.In(time.Local)
+		}
+
+		e := event{
+			Message:   summary,
+			When:      at.Format(format),
+			Next:      at,
+			ChannelId: channelId,
+			Dm:        strings.EqualFold(component.value("X-GRUMPY-DM"), "TRUE"),
+			Ping:      ping,
+			Quote:     unescapeText(component.value("X-GRUMPY-QUOTE")),
+			Link:      component.value("URL"),
+		}
+
+		// rules are rebuilt around the alarm's time of day, which only
+		// works while it stays on the event's day
+		if (len(rule) > 0 || len(rrule) > 0) && at.Format(icsDate) != start.In(at.Location()).Format(icsDate) {
+			return nil, fmt.Errorf("alarms on another day than a repeating event aren't supported")
+		}
+
+		if len(rule) == 0 && len(rrule) > 0 {
+			converted, err := ruleFromRRule(rrule, at)
+			if err != nil {
+				return nil, err
+			}
+			e.Rule = converted
+		} else {
+			e.Rule = rule
+		}
+
+		if len(e.Rule) > 0 {
+			sched, err := parseRule(e.Rule)
+			if err != nil {
+				return nil, err
+			}
+			e.sched = sched
+			e.Zone = zoneName(at.Location())
+			if !e.reschedule(now) {
+				return nil, fmt.Errorf("it never happens again")
+			}
+		} else if !e.Next.After(now) {
+			return nil, fmt.Errorf("it's in the past")
+		}
+
+		e.When = e.Next.Format(format)
+		events = append(events, &e)
+	}
+
+	return events, nil
+}
+
+// parseICSTime reads DATE-TIME values in UTC, with a TZID or floating, and
+// DATE values, which are taken to mean 9am.
+func parseICSTime(prop icsProp, loc *time.Location) (time.Time, error) {
+	value := prop.value
+
+	if tzid, ok := prop.params["TZID"]; ok {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			loc = zone
+		}
+	}
+
+	if prop.params["VALUE"] == "DATE" || len(value) == len(icsDate) {
+		t, err := time.ParseInLocation(icsDate, value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		return t.Add(9 * time.Hour), nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTimeUTC, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", value)
+		}
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(icsDateTime, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+
+	return t, nil
+}
+
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICSDuration reads durations like -PT15M or P1DT2H.
+func parseICSDuration(value string) (time.Duration, error) {
+	match := icsDuration.FindStringSubmatch(strings.ToUpper(value))
+	if match == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var d time.Duration
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for index, unit := range units {
+		if n, err := strconv.Atoi(match[index+2]); err == nil {
+			d += time.Duration(n) * unit
+		}
+	}
+
+	if match[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// alarmTime resolves a VALARM trigger, either an absolute time or an offset
+// from the start or end of the event.
+func alarmTime(trigger icsProp, start time.Time, end time.Time, loc *time.Location) (time.Time, error) {
+	if trigger.params["VALUE"] == "DATE-TIME" {
+		return parseICSTime(icsProp{value: trigger.value, params: map[string]string{}}, loc)
+	}
+
+	d, err := parseICSDuration(trigger.value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if trigger.params["RELATED"] == "END" {
+		return end.Add(d), nil
+	}
+
+	return start.Add(d), nil
+}
+
+var ordinals = map[int]string{1: "st", 2: "nd", 3: "rd", 21: "st", 22: "nd", 23: "rd", 31: "st"}
+
+// ruleFromRRule translates the common RRULEs into a phrase the schedule
+// parser understands, using start for the time of day and the days that the
+// RRULE leaves out.
+func ruleFromRRule(rrule string, start time.Time) (string, error) {
+	parts := make(map[string]string)
+	for _, part := range strings.Split(strings.ToUpper(rrule), ";") {
+		key, value, _ := strings.Cut(part, "=")
+		parts[key] = value
+	}
+
+	for _, unsupported := range []string{"COUNT", "UNTIL", "BYSETPOS", "BYWEEKNO", "BYYEARDAY", "BYSECOND"} {
+		if _, ok := parts[unsupported]; ok {
+			return "", fmt.Errorf("%s in a repeat rule isn't supported", unsupported)
+		}
+	}
+
+	interval := 1
+	if value, ok := parts["INTERVAL"]; ok {
+		var err error
+		if interval, err = strconv.Atoi(value); err != nil || interval < 1 {
+			return "", fmt.Errorf("invalid INTERVAL %q", value)
+		}
+	}
+
+	hour, minute := start.Hour(), start.Minute()
+	for key, target := range map[string]*int{"BYHOUR": &hour, "BYMINUTE": &minute} {
+		if value, ok := parts[key]; ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "", fmt.Errorf("%s with several values isn't supported", key)
+			}
+			*target = n
+		}
+	}
+	at := fmt.Sprintf("at %d:%02d", hour, minute)
+
+	every := func(unit string) string {
+		if interval > 1 {
+			return fmt.Sprintf("every %d %ss", interval, unit)
+		}
+		return "every " + unit
+	}
+
+	var weekdays []string
+	if value, ok := parts["BYDAY"]; ok {
+		for _, day := range strings.Split(value, ",") {
+			index := -1
+			for i, name := range icsDays {
+				if day == name {
+					index = i
+				}
+			}
+			if index < 0 {
+				return "", fmt.Errorf("BYDAY %q isn't supported", day)
+			}
+			weekdays = append(weekdays, strings.ToLower(time.Weekday(index).String()))
+		}
+	}
+
+	if _, ok := parts["BYMONTH"]; ok && parts["FREQ"] != "YEARLY" {
+		return "", fmt.Errorf("BYMONTH isn't supported")
+	}
+
+	switch parts["FREQ"] {
+	case "MINUTELY":
+		return every("minute"), nil
+	case "HOURLY":
+		return every("hour"), nil
+	case "DAILY":
+		if weekdays != nil {
+			if interval > 1 {
+				return "", fmt.Errorf("daily rules on some days with an interval aren't supported")
+			}
+			return fmt.Sprintf("every week on %s %s", strings.Join(weekdays, ", "), at), nil
+		}
+		return fmt.Sprintf("%s %s", every("day"), at), nil
+	case "WEEKLY":
+		if weekdays == nil {
+			weekdays = []string{strings.ToLower(start.Weekday().String())}
+		}
+		sort.SliceStable(weekdays, func(a, b int) bool {
+			return weekdayNames[weekdays[a]] < weekdayNames[weekdays[b]]
+		})
+		return fmt.Sprintf("%s on %s %s", every("week"), strings.Join(weekdays, ", "), at), nil
+	case "MONTHLY":
+		if weekdays != nil {
+			return "", fmt.Errorf("monthly rules on weekdays aren't supported")
+		}
+		day := start.Day()
+		if value, ok := parts["BYMONTHDAY"]; ok {
+			var err error
+			if day, err = strconv.Atoi(value); err != nil || day < 1 || day > 31 {
+				return "", fmt.Errorf("BYMONTHDAY %q isn't supported", value)
+			}
+		}
+		return fmt.Sprintf("%s on the %d%s %s", every("month"), day, ordinal(day), at), nil
+	case "YEARLY":
+		if interval > 1 || weekdays != nil {
+			return "", fmt.Errorf("this yearly rule isn't supported")
+		}
+		month, day := int(start.Month()), start.Day()
+		if value, ok := parts["BYMONTH"]; ok {
+			month, _ = strconv.Atoi(value)
+		}
+		if value, ok := parts["BYMONTHDAY"]; ok {
+			day, _ = strconv.Atoi(value)
+		}
+		return fmt.Sprintf("%d %d %d %d *", minute, hour, day, month), nil
+	}
+
+	return "", fmt.Errorf("FREQ %q isn't supported", parts["FREQ"])
+}
+
+func ordinal(day int) string {
+	if suffix, ok := ordinals[day]; ok {
+		return suffix
+	}
+	return "th"
+}
@@ -0,0 +1,192 @@
+package reminder
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleEvents(t *testing.T) []*event {
+	var sampled []*event
+
+	for _, e := range []*event{
+		{Id: "a1b2c3", Message: "Deploy, then; relax", Next: date("2022-10-28 17:00"), ChannelId: "1", Ping: []string{"<@&5>", "@here"}},
+		{Id: "d4e5f6", Message: "standup", Next: date("2022-10-27 13:00"), ChannelId: "1", Rule: "every weekday at 9am", Zone: "America/New_York"},
+		{Id: "0a0b0c", Message: "invoices", Next: date("2022-11-01 09:00"), ChannelId: "1", Rule: "0 9 1 * *", Zone: "UTC", Dm: true},
+		{Id: "112233", Message: "odd cron", Next: date("2022-11-01 09:00"), ChannelId: "1", Rule: "0 9 1 * fri", Zone: "UTC"},
+		{Id: "445566", Message: "look at this", Next: date("2022-10-27 12:00"), ChannelId: "1", Quote: "a very long message that goes on and on so the line has to be folded somewhere", Link: "https://discord.com/channels/1/2/3"},
+	} {
+		if len(e.Zone) > 0 {
+			if _, err := time.LoadLocation(e.Zone); err != nil {
+				t.Skip("no timezone database")
+			}
+		}
+		sampled = append(sampled, e)
+	}
+
+	return sampled
+}
+
+func TestExportICS(t *testing.T) {
+	got := exportICS(sampleEvents(t), date("2022-10-26 10:00"))
+
+	want, err := os.ReadFile("testdata/export.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("exportICS differs from testdata/export.ics:\n%s", got)
+	}
+
+	for _, line := range strings.Split(string(got), "\r\n") {
+		if len(line) > icsLineLength {
+			t.Errorf("line longer than %d octets: %q", icsLineLength, line)
+		}
+	}
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	now := date("2022-10-26 10:00")
+	exported := sampleEvents(t)
+
+	imported, notes, err := importICS(exportICS(exported, now), "2", now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notes) > 0 {
+		t.Errorf("unexpected notes: %q", notes)
+	}
+
+	if len(imported) != len(exported) {
+		t.Fatalf("imported %d events, want %d", len(imported), len(exported))
+	}
+
+	for index, e := range imported {
+		want := exported[index]
+
+		if e.Message != want.Message || e.Rule != want.Rule || e.Link != want.Link || !e.Next.Equal(want.Next) {
+			t.Errorf("event %d came back as %q %q %q %s, want %q %q %q %s", index, e.Message, e.Rule, e.Link, e.Next, want.Message, want.Rule, want.Link, want.Next)
+		}
+
+		if e.Dm != want.Dm || strings.Join(e.Ping, " ") != strings.Join(want.Ping, " ") || e.Quote != want.Quote {
+			t.Errorf("event %d came back with dm %t ping %q quote %q, want %t %q %q", index, e.Dm, e.Ping, e.Quote, want.Dm, want.Ping, want.Quote)
+		}
+
+		if e.ChannelId != "2" {
+			t.Errorf("event %d was imported into channel %q", index, e.ChannelId)
+		}
+	}
+}
+
+func TestICSRoundTripLocalZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	local := time.Local
+	time.Local = newYork
+	defer func() { time.Local = local }()
+
+	now := date("2022-10-26 10:00")
+	exported := []*event{{Id: "778899", Message: "standup", Next: time.Date(2022, 10, 27, 9, 0, 0, 0, time.Local), ChannelId: "1", Rule: "every weekday at 9am"}}
+
+	imported, notes, err := importICS(exportICS(exported, now), "2", now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) > 0 || len(imported) != 1 {
+		t.Fatalf("imported %d events with notes %q", len(imported), notes)
+	}
+
+	e := imported[0]
+	if len(e.Zone) > 0 || !e.Next.Equal(exported[0].Next) {
+		t.Errorf("came back in zone %q at %s, want the local zone at %s", e.Zone, e.Next, exported[0].Next)
+	}
+
+	sched, next := e.recurrence()
+	if after, want := sched.Next(next), time.Date(2022, 10, 28, 9, 0, 0, 0, newYork); !after.Equal(want) {
+		t.Errorf("next occurrence after import is %s, want %s", after, want)
+	}
+}
+
+func TestImportSharedCalendar(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	data, err := os.ReadFile("testdata/shared.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := date("2022-10-26 10:00")
+	imported, notes, err := importICS(data, "1", now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		message string
+		next    time.Time
+		rule    string
+	}{
+		{"Release 2.3, final checks", time.Date(2022, 11, 4, 13, 45, 0, 0, newYork), ""},
+		{"Release 2.3, final checks", time.Date(2022, 11, 4, 15, 0, 0, 0, newYork), ""},
+		{"Standup", time.Date(2022, 10, 26, 9, 30, 0, 0, newYork), "every week on monday, wednesday, friday at 9:30"},
+		{"Retrospective", date("2022-11-04 19:00"), "every 2 weeks on friday at 19:00"},
+		{"Send invoices", date("2022-11-01 09:00"), "every month on the 1st at 9:00"},
+	}
+
+	if len(imported) != len(want) {
+		for _, e := range imported {
+			t.Logf("imported %q %s %q", e.Message, e.Next, e.Rule)
+		}
+		t.Fatalf("imported %d events, want %d", len(imported), len(want))
+	}
+
+	for index, e := range imported {
+		if e.Message != want[index].message || !e.Next.Equal(want[index].next) || e.Rule != want[index].rule {
+			t.Errorf("event %d = %q %s %q, want %q %s %q", index, e.Message, e.Next, e.Rule, want[index].message, want[index].next, want[index].rule)
+		}
+	}
+
+	if len(notes) != 2 || !strings.Contains(notes[0], "Already happened") || !strings.Contains(notes[1], "COUNT") {
+		t.Errorf("notes = %q, want the past event and the COUNT rule", notes)
+	}
+}
+
+func TestParseICSErrors(t *testing.T) {
+	inputs := []string{
+		"",
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nEND:VEVENT\r\n",
+		"BEGIN:VCALENDAR\r\nno colon here\r\nEND:VCALENDAR\r\n",
+	}
+
+	for _, input := range inputs {
+		if _, err := parseICS([]byte(input)); err == nil {
+			t.Errorf("parseICS(%q) expected an error", input)
+		}
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT0S":     0,
+		"-PT15M":   -15 * time.Minute,
+		"P1DT2H":   26 * time.Hour,
+		"-P1W":     -7 * 24 * time.Hour,
+		"+PT1H30M": 90 * time.Minute,
+	}
+
+	for input, want := range tests {
+		if got, err := parseICSDuration(input); err != nil || got != want {
+			t.Errorf("parseICSDuration(%q) = %s, %v, want %s", input, got, err, want)
+		}
+	}
+}
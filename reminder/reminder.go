@@ -1,293 +1,671 @@
 package reminder
 
 import (
-	"encoding/json"
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/bcampbell/fuzzytime"
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+	"rawrippers.com/grumpy-daemon/store"
+	"rawrippers.com/grumpy-daemon/timezone"
+	"rawrippers.com/grumpy-daemon/utils"
 )
 
 type event struct {
+	Id        string `json:",omitempty"`
 	Message   string
 	When      string
 	Next      time.Time
 	ChannelId string
+	Rule      string   `json:",omitempty"`
+	Zone      string   `json:",omitempty"`
+	AuthorId  string   `json:",omitempty"`
+	Dm        bool     `json:",omitempty"`
+	Ping      []string `json:",omitempty"`
+	Quote     string   `json:",omitempty"`
+	Link      string   `json:",omitempty"`
+
+	sched schedule
+	index int
 }
 
 var (
 	mu     sync.Mutex
-	events []*event
+	events eventHeap
+	repo   store.Repository[*event]
 )
 
-const format = "2006-01-02T15:04-07:00"
+const (
+	format     = "2006-01-02T15:04-07:00"
+	listFormat = "2006-01-02 15:04 -0700"
+)
 
-func SetReminder(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func SetReminder(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: set(s, i),
+			Content: set(s, i, args),
 		},
 	})
 }
 
-func ListReminders(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// ListPages is the custom id prefix of the /list_reminders page buttons.
+const ListPages = "list_reminders"
+
+func ListReminders(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: list(i).Page(0),
+	})
+}
+
+// ListRemindersPage shows another page of /list_reminders.
+func ListRemindersPage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	list(i).Turn(s, i, utils.PageOf(i))
+}
+
+// Load reads the saved reminders. Call it before Poll. If they can't be
+// read nothing is kept to save over them, and the daemon shouldn't start.
+func Load(st *store.Store) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loading := store.Collection[*event](st, "events")
+
+	loaded, err := loading.Load()
+	if err != nil {
+		return fmt.Errorf("could not load events: %w", err)
+	}
+	repo = loading
+
+	events = loaded
+	log.Printf("loaded %d events", len(events))
+
+	for index, e := range events {
+		e.index = index
+	}
+	heap.Init(&events)
+	if assignIds() {
+		write()
+	}
+
+	return nil
+}
+
+// Poll delivers reminders as they come due until ctx is done. A reminder
+// being delivered and saved when it is finishes first.
+func Poll(ctx context.Context, s *discordgo.Session) {
+	run(realClock{}, func(d delivery) {
+		deliver(s, d)
+	}, write, ctx.Done())
+}
+
+func DeleteReminder(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: list(i),
+			Content: remove(s, i, args),
 		},
 	})
 }
 
-func Poll(s *discordgo.Session) {
+// DeleteReminderAutocomplete suggests the channel's reminders, soonest first,
+// for /delete_reminder.
+func DeleteReminderAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	utils.Suggest(s, i, args.String(args.Focused), choices(i.ChannelID))
+}
+
+func choices(channelId string) []utils.Choice {
 	mu.Lock()
+	defer mu.Unlock()
 
-	read()
+	sorted := make([]*event, 0, len(events))
+	for _, e := range events {
+		if e.ChannelId == channelId {
+			sorted = append(sorted, e)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Next.Before(sorted[b].Next)
+	})
+
+	choices := make([]utils.Choice, len(sorted))
+	for n, e := range sorted {
+		choices[n] = utils.Choice{Name: fmt.Sprintf("%s: %s", e.Id, e.Message), Value: e.Id}
+	}
 
+	return choices
+}
+
+func remove(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	reminder := args.String("reminder")
+
+	mu.Lock()
+	e, err := find(i.ChannelID, reminder)
+	if err != nil {
+		mu.Unlock()
+		return findError(reminder, err)
+	}
+	if !permission.MayDelete(i, e.AuthorId, "deleting reminder "+e.Id) {
+		mu.Unlock()
+		return fmt.Sprintf("Reminder `%s` isn't yours, you need `%s` to delete it.", e.Id, permission.DeleteAny)
+	}
+	heap.Remove(&events, e.index)
+	write()
 	mu.Unlock()
 
-	for {
-		mu.Lock()
+	notify()
 
-		now := time.Now()
+	return fmt.Sprintf("<@%s> deleted reminder `%s` `%s`.", args.User.ID, e.Id, e.Message)
+}
 
-		toRemove := []int{}
+func EditReminder(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: edit(s, i, args),
+		},
+	})
+}
 
-		for i, event := range events {
-			if event.Next.Before(now) {
-				s.ChannelMessageSend(event.ChannelId, event.Message)
-				toRemove = append(toRemove, i)
-			}
+func edit(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	id := args.String("id")
+
+	// Checking the channel and pings can go to Discord, so do it without
+	// holding up the scheduler.
+	var channel *discordgo.Channel
+	var perms int64
+	if args.Has("channel") {
+		var err error
+		if channel, perms, err = postChannel(s, i, args); err != nil {
+			return err.Error()
 		}
+	}
 
-		newIndexMap := make(map[int]int)
-		for _, removeIndex := range toRemove {
-			if newIndex, ok := newIndexMap[removeIndex]; ok {
-				events[newIndex] = events[len(events)-1]
-				newIndexMap[len(events)-1] = newIndex
-				events = events[:len(events)-1]
-			} else {
-				events[removeIndex] = events[len(events)-1]
-				newIndexMap[len(events)-1] = removeIndex
-				events = events[:len(events)-1]
-			}
+	mu.Lock()
+	e, err := find(i.ChannelID, id)
+	if err != nil {
+		mu.Unlock()
+		return findError(id, err)
+	}
+	if !permission.MayDelete(i, e.AuthorId, "changing reminder "+e.Id) {
+		mu.Unlock()
+		return fmt.Sprintf("Reminder `%s` isn't yours, you need `%s` to change it.", e.Id, permission.DeleteAny)
+	}
+	found, dm, ping := e.Id, e.Dm, e.Ping
+	mu.Unlock()
+
+	if channel != nil {
+		if dm {
+			return "A reminder can go to your DMs or a channel, not both."
+		}
+		// Its pings were allowed for whoever set it where it was set, so
+		// check them again for whoever moves it where it's going.
+		if err := allowPing(s, i.GuildID, ping, perms); err != nil {
+			return err.Error()
 		}
+	}
 
-		write()
+	mu.Lock()
+	defer mu.Unlock()
 
-		mu.Unlock()
+	e, err = find(i.ChannelID, found)
+	if err != nil {
+		return findError(id, err)
+	}
+
+	changed := *e
 
-		time.Sleep(500 * time.Millisecond)
+	if args.Has("message") {
+		changed.Message = args.String("message")
 	}
+
+	if args.Has("when") {
+		changed.When = args.String("when")
+		changed.Next, err = parseWhen(changed.When, time.Now(), timezone.Location(args.User.ID))
+		if err != nil {
+			return fmt.Sprintf("I didn't understand that (%s).", err)
+		}
+	}
+
+	if channel != nil {
+		changed.ChannelId = channel.ID
+	}
+
+	*e = changed
+	heap.Fix(&events, e.index)
+	write()
+	notify()
+
+	return fmt.Sprintf("<@%s> changed reminder `%s` to `%s` at <t:%d:F> in <#%s>.", args.User.ID, e.Id, e.Message, e.Next.Unix(), e.ChannelId)
 }
 
-func DeleteReminder(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func SnoozeReminder(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: delete(s, i),
+			Content: snooze(s, i, args),
 		},
 	})
 }
 
-func delete(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
+func snooze(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	id := args.String("id")
+	duration := args.String("duration")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	e, err := find(i.ChannelID, id)
+	if err != nil {
+		return findError(id, err)
 	}
 
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
+	if !permission.MayDelete(i, e.AuthorId, "snoozing reminder "+e.Id) {
+		return fmt.Sprintf("Reminder `%s` isn't yours, you need `%s` to snooze it.", e.Id, permission.DeleteAny)
 	}
 
-	options := i.ApplicationCommandData().Options
+	// snoozing pushes the reminder back from whichever is later, its next
+	// time or now
+	from := e.Next
+	if now := time.Now(); now.After(from) {
+		from = now
+	}
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
+	next, err := parseOffset(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(duration)), "in "), from)
+	if err != nil {
+		return fmt.Sprintf("I didn't understand that (%s). Try `10m`, `2 hours` or `1d`.", err)
 	}
 
-	var message string
+	e.Next = next
+	e.When = next.Format(format)
+	heap.Fix(&events, e.index)
+	write()
+	notify()
 
-	if option, ok := optionMap["message"]; ok {
-		message = option.StringValue()
-	} else {
-		return "Message is required."
-	}
+	return fmt.Sprintf("<@%s> snoozed reminder `%s` `%s` until <t:%d:F>.", args.User.ID, e.Id, e.Message, e.Next.Unix())
+}
 
-	deleted := false
-	mu.Lock()
-	for index, r := range events {
-		if r.ChannelId == i.ChannelID && r.Message == message {
-			events[index] = events[len(events)-1]
-			events = events[:len(events)-1]
-			deleted = true
+var (
+	errNotFound  = errors.New("reminder not found")
+	errAmbiguous = errors.New("reminder is ambiguous")
+)
+
+// find looks up a channel's reminder by id, or by its exact message as long as
+// only one reminder has it. mu must be held.
+func find(channelId string, key string) (*event, error) {
+	var matches []*event
+
+	for _, e := range events {
+		if e.ChannelId != channelId {
+			continue
+		}
+		if e.Id == key {
+			return e, nil
+		}
+		if e.Message == key {
+			matches = append(matches, e)
 		}
 	}
-	mu.Unlock()
 
-	if deleted {
-		return fmt.Sprintf("<@%s> deleted reminder `%s`.", i.Member.User.ID, message)
-	} else {
-		return fmt.Sprintf("Could not find reminder `%s` to deleted.", message)
+	if len(matches) == 1 {
+		return matches[0], nil
 	}
+
+	if len(matches) > 1 {
+		return nil, errAmbiguous
+	}
+
+	return nil, errNotFound
 }
 
-func list(i *discordgo.InteractionCreate) string {
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
+func findError(key string, err error) string {
+	if err == errAmbiguous {
+		return fmt.Sprintf("More than one reminder says `%s`, use its id from /list_reminders.", key)
 	}
+	return fmt.Sprintf("Could not find reminder `%s`.", key)
+}
+
+// newId returns a short id that no other event uses. mu must be held.
+func newId() string {
+	for {
+		b := make([]byte, 3)
+		if _, err := rand.Read(b); err != nil {
+			log.Print(err)
+		}
 
-	eventsResponse := ""
+		id := hex.EncodeToString(b)
+
+		taken := false
+		for _, e := range events {
+			if e.Id == id {
+				taken = true
+				break
+			}
+		}
+
+		if !taken {
+			return id
+		}
+	}
+}
+
+// assignIds gives an id to events loaded from files written before ids
+// existed, and reports whether any were added. mu must be held.
+func assignIds() bool {
+	assigned := false
+
+	for _, e := range events {
+		if len(e.Id) == 0 {
+			e.Id = newId()
+			assigned = true
+		}
+	}
+
+	return assigned
+}
+
+func list(i *discordgo.InteractionCreate) utils.List {
+	l := utils.List{Prefix: ListPages, Title: "Upcoming reminders", Empty: "no upcoming events"}
 
 	mu.Lock()
 
-	for _, event := range events {
+	sorted := make([]*event, len(events))
+	copy(sorted, events)
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Next.Before(sorted[b].Next)
+	})
+
+	for _, event := range sorted {
 		if event.ChannelId == i.ChannelID {
-			eventsResponse = fmt.Sprintf("%s\n%s\t\t%s", eventsResponse, event.When, event.Message)
+			line := fmt.Sprintf("`%s`\t<t:%d:F>", event.Id, event.Next.Unix())
+			if len(event.Rule) > 0 {
+				line = fmt.Sprintf("%s\t`%s`", line, event.Rule)
+			}
+			if target := event.target(); len(target) > 0 {
+				line = fmt.Sprintf("%s\t%s", line, target)
+			}
+			line = fmt.Sprintf("%s\t%s", line, event.Message)
+			if len(event.Link) > 0 {
+				line = fmt.Sprintf("%s %s", line, event.Link)
+			}
+			l.Lines = append(l.Lines, line)
 		}
 	}
 
 	mu.Unlock()
 
-	if len(eventsResponse) == 0 {
-		return "no upcoming events"
-	}
-
-	return fmt.Sprintf("```%s```", eventsResponse)
+	return l
 }
 
-func set(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
+func set(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	message := args.String("message")
+	when := args.String("when")
+	rule := args.String("repeat")
+
+	if len(when) == 0 && len(rule) == 0 {
+		return "When or repeat is required."
 	}
 
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
+	channelId := i.ChannelID
+	dm := args.Bool("dm")
+	var ping []string
+
+	var perms int64
+	if i.Member != nil {
+		perms = i.Member.Permissions
 	}
 
-	options := i.ApplicationCommandData().Options
+	if args.Has("channel") {
+		if dm {
+			return "A reminder can go to your DMs or a channel, not both."
+		}
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
+		channel, channelPerms, err := postChannel(s, i, args)
+		if err != nil {
+			return err.Error()
+		}
+		perms = channelPerms
+		channelId = channel.ID
 	}
 
-	var message string
-	var when string
+	if args.Has("ping") {
+		if dm {
+			return "There's nobody to ping in your DMs."
+		}
 
-	if option, ok := optionMap["message"]; ok {
-		message = option.StringValue()
-	} else {
-		return "Message is required."
+		var err error
+		ping, err = parsePing(args.String("ping"))
+		if err != nil {
+			return fmt.Sprintf("%s.", err)
+		}
+
+		if err := allowPing(s, i.GuildID, ping, perms); err != nil {
+			return err.Error()
+		}
 	}
 
-	if option, ok := optionMap["when"]; ok {
-		when = option.StringValue()
+	var event *event
+	var err error
+
+	loc := timezone.Location(args.User.ID)
+
+	if len(rule) > 0 {
+		event, err = buildRecurringEvent(message, when, rule, channelId, time.Now(), loc)
 	} else {
-		return "When is required."
+		event, err = buildEvent(message, when, channelId, time.Now(), loc)
 	}
 
-	event, err := buildEvent(message, when, i.ChannelID)
-
 	if err != nil {
-		return fmt.Sprintf("I didn't understand that. Example date: %s", format)
+		return fmt.Sprintf("I didn't understand that (%s). Try `in 20 minutes`, `tomorrow at noon`, `next tuesday 9am` or `2022-10-29 08:43 -0400`, and for repeat `every weekday at 9am` or `0 9 1 * *`.", err)
 	}
 
+	event.AuthorId = args.User.ID
+	event.Dm = dm
+	event.Ping = ping
+
 	mu.Lock()
-	events = append(events, event)
+	event.Id = newId()
+	heap.Push(&events, event)
 	write()
 	mu.Unlock()
 
-	return fmt.Sprintf("<@%s> set a reminder `%s` at `%s`. Use /list_reminders to see reminders.", i.Member.User.ID, message, when)
-}
+	notify()
 
-func buildEvent(message string, when string, channelId string) (*event, error) {
-	tokens := strings.Split(when, " ")
+	where := ""
+	if dm {
+		where = " in your DMs"
+	} else if channelId != i.ChannelID {
+		where = fmt.Sprintf(" in <#%s>", channelId)
+	}
 
-	if len(tokens) == 0 {
-		return nil, fmt.Errorf("invalid token length")
+	if len(rule) > 0 {
+		return fmt.Sprintf("<@%s> set a reminder `%s` `%s`%s repeating `%s`, next at <t:%d:F>. Use /list_reminders to see reminders.", args.User.ID, event.Id, message, where, rule, event.Next.Unix())
 	}
 
-	extractedTime, _, err := fuzzytime.Extract(when)
+	return fmt.Sprintf("<@%s> set a reminder `%s` `%s`%s at <t:%d:F>. Use /list_reminders to see reminders.", args.User.ID, event.Id, message, where, event.Next.Unix())
+}
+
+// postChannel resolves the channel option and checks that the member running
+// the command may post there, returning their permissions in it.
+func postChannel(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) (*discordgo.Channel, int64, error) {
+	channel := args.Channel(s, "channel")
+	if channel == nil || channel.GuildID != i.GuildID {
+		return nil, 0, errors.New("That channel isn't in this server.")
+	}
 
+	perms, err := s.UserChannelPermissions(args.User.ID, channel.ID)
 	if err != nil {
 		log.Print(err)
-		return nil, fmt.Errorf("could not parse when")
+		return nil, 0, errors.New("I couldn't check your permissions in that channel.")
 	}
 
-	parsed, err := time.Parse(format, extractedTime.ISOFormat())
+	if perms&discordgo.PermissionAdministrator == 0 && perms&(discordgo.PermissionViewChannel|discordgo.PermissionSendMessages) != discordgo.PermissionViewChannel|discordgo.PermissionSendMessages {
+		return nil, 0, fmt.Errorf("You can't post in <#%s>.", channel.ID)
+	}
+
+	return channel, perms, nil
+}
 
+// allowPing checks that perms let the member ping everyone in ping.
+func allowPing(s *discordgo.Session, guildId string, ping []string, perms int64) error {
+	err := checkPing(ping, perms, func(id string) (*discordgo.Role, error) {
+		return guildRole(s, guildId, id)
+	})
 	if err != nil {
-		log.Print(err)
-		return nil, fmt.Errorf("could not parse when")
+		return fmt.Errorf("Sorry, %s.", err)
+	}
+	return nil
+}
+
+// guildRole looks a role up in the state cache, falling back to the API.
+func guildRole(s *discordgo.Session, guildId string, roleId string) (*discordgo.Role, error) {
+	if role, err := s.State.Role(guildId, roleId); err == nil {
+		return role, nil
+	}
+
+	roles, err := s.GuildRoles(guildId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		if role.ID == roleId {
+			return role, nil
+		}
+	}
+
+	return nil, fmt.Errorf("role %s not found", roleId)
+}
+
+// buildRecurringEvent creates an event that repeats according to rule. If
+// when is given it is used as the first occurrence, otherwise the rule's first
+// occurrence after now is.
+func buildRecurringEvent(message string, when string, rule string, channelId string, now time.Time, loc *time.Location) (*event, error) {
+	sched, err := parseRule(rule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(when) > 0 {
+		event, err := buildEvent(message, when, channelId, now, loc)
+		if err != nil {
+			return nil, err
+		}
+		event.Rule = rule
+		event.Zone = zoneName(loc)
+		event.sched = sched
+		return event, nil
+	}
+
+	next := sched.First(now.In(loc))
+
+	if next.IsZero() {
+		return nil, fmt.Errorf("rule never fires")
 	}
 
 	event := event{
 		Message:   message,
-		When:      when,
-		Next:      parsed,
+		When:      next.Format(format),
+		Next:      next,
 		ChannelId: channelId,
+		Rule:      rule,
+		Zone:      zoneName(loc),
+		sched:     sched,
 	}
 
 	return &event, nil
 }
 
-func write() {
-	createDirs()
-	homedir := homeDir()
-	file, err := json.MarshalIndent(&events, "", " ")
+// reschedule moves a recurring event to its next occurrence after now and
+// reports whether the event should be kept.
+func (e *event) reschedule(now time.Time) bool {
+	sched, next := e.recurrence()
+	if sched == nil {
+		return false
+	}
 
-	if err != nil {
-		log.Fatal(err)
+	if !next.After(now) {
+		next = sched.Skip(next, now)
+	}
+	for !next.After(now) {
+		next = sched.Next(next)
+		if next.IsZero() {
+			return false
+		}
 	}
 
-	err = os.WriteFile(fmt.Sprintf("%s/.grumpy/events/events.json", homedir), file, 0644)
+	e.Next = next
+	e.When = next.Format(format)
 
-	if err != nil {
-		log.Fatal(err)
-	}
+	return true
 }
 
-func read() {
-	createDirs()
-	homedir := homeDir()
-	file, err := os.ReadFile(fmt.Sprintf("%s/.grumpy/events/events.json", homedir))
+// recurrence returns e's parsed rule, or nil for one-off events, along with
+// its next time in the zone the rule is evaluated in.
+func (e *event) recurrence() (schedule, time.Time) {
+	if len(e.Rule) == 0 {
+		return nil, e.Next
+	}
 
-	if err != nil {
-		log.Printf("Could not open events: %s", err)
-		return
+	if e.sched == nil {
+		sched, err := parseRule(e.Rule)
+		if err != nil {
+			log.Printf("dropping reminder with bad rule %q: %s", e.Rule, err)
+			return nil, e.Next
+		}
+		e.sched = sched
 	}
 
-	err = json.Unmarshal(file, &events)
+	next := e.Next
+	if len(e.Zone) > 0 {
+		if loc, err := time.LoadLocation(e.Zone); err == nil {
+			next = next.In(loc)
+		}
+	}
 
-	log.Printf("loaded %d events", len(events))
+	return e.sched, next
+}
 
-	if err != nil {
-		log.Print(err)
+// zoneName is the name a recurring event stores so its rule keeps following
+// daylight saving changes. The server's local zone is left implicit.
+func zoneName(loc *time.Location) string {
+	if loc == time.Local {
+		return ""
 	}
+	return loc.String()
 }
 
-func homeDir() string {
-	homedir, err := os.UserHomeDir()
+func buildEvent(message string, when string, channelId string, now time.Time, loc *time.Location) (*event, error) {
+	next, err := parseWhen(when, now, loc)
+
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	return homedir
-}
 
-func createDirs() {
-	homedir := homeDir()
+	event := event{
+		Message:   message,
+		When:      when,
+		Next:      next,
+		ChannelId: channelId,
+	}
 
-	path := fmt.Sprintf("%s/.grumpy/events/", homedir)
-	err := os.MkdirAll(path, os.ModePerm)
+	return &event, nil
+}
 
-	if err != nil {
-		log.Print(err)
+func write() {
+	if err := repo.Save(events); err != nil {
+		log.Printf("could not save events: %s", err)
 	}
 }
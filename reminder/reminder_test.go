@@ -0,0 +1,68 @@
+package reminder
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAssignIdsToOldEvents(t *testing.T) {
+	old := `[
+ {
+  "Message": "standup",
+  "When": "2022-10-29 08:43 -0400",
+  "Next": "2022-10-29T08:43:00-04:00",
+  "ChannelId": "1"
+ },
+ {
+  "Message": "standup",
+  "When": "2022-10-30 08:43 -0400",
+  "Next": "2022-10-30T08:43:00-04:00",
+  "ChannelId": "1"
+ }
+]`
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	events = nil
+	if err := json.Unmarshal([]byte(old), &events); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assignIds() {
+		t.Fatal("no ids were assigned")
+	}
+
+	if events[0].Id == "" || events[0].Id == events[1].Id {
+		t.Errorf("ids are not unique: %q %q", events[0].Id, events[1].Id)
+	}
+
+	if assignIds() {
+		t.Error("ids were assigned twice")
+	}
+
+	if _, err := find("1", "standup"); err != errAmbiguous {
+		t.Errorf("find by shared message = %v, want errAmbiguous", err)
+	}
+
+	if e, err := find("1", events[1].Id); err != nil || e != events[1] {
+		t.Errorf("find by id = %v, %v", e, err)
+	}
+
+	if _, err := find("2", events[1].Id); err != errNotFound {
+		t.Errorf("find in another channel = %v, want errNotFound", err)
+	}
+}
+
+func TestEventTextQuotesMessage(t *testing.T) {
+	e := event{
+		Message: "look at this",
+		Quote:   "first line\nsecond line",
+		Link:    "https://discord.com/channels/1/2/3",
+	}
+
+	want := "look at this\n> first line\n> second line\nhttps://discord.com/channels/1/2/3"
+	if got := e.text(); got != want {
+		t.Errorf("text() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,526 @@
+package reminder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule computes the occurrences of a recurring reminder.
+type schedule interface {
+	// First returns the first occurrence strictly after t.
+	First(t time.Time) time.Time
+	// Next returns the occurrence that follows prev, which must itself be an occurrence.
+	Next(prev time.Time) time.Time
+	// Skip returns an occurrence from prev on that is no later than t but
+	// close to it, or prev if there's no shortcut, so stepping to t with
+	// Next doesn't take long after a long outage.
+	Skip(prev time.Time, t time.Time) time.Time
+}
+
+// parseRule accepts either a five field cron expression or a phrase such as
+// "every weekday at 9am" or "every 2 weeks on Friday".
+func parseRule(rule string) (schedule, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+
+	if len(rule) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+
+	if strings.HasPrefix(rule, "every") || rule == "daily" || rule == "weekly" || rule == "monthly" || rule == "hourly" {
+		return parsePhrase(rule)
+	}
+
+	return parseCron(rule)
+}
+
+// cron
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDays = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+func parseCron(rule string) (*cronSchedule, error) {
+	fields := strings.Fields(rule)
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expressions need 5 fields, got %d", len(fields))
+	}
+
+	var c cronSchedule
+	var err error
+
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDays); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+
+	// 7 is an alias for sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return &c, nil
+}
+
+func parseCronField(field string, min int, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[index+1:])
+			}
+			part = part[:index]
+		}
+
+		lo, hi := min, max
+		if part != "*" && part != "?" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			if lo, err = parseCronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(value string, names map[string]int) (int, error) {
+	if v, ok := names[value]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	return v, nil
+}
+
+func (c *cronSchedule) First(t time.Time) time.Time {
+	return c.Next(t)
+}
+
+// Skip looks back from t over a growing window, since any time can be where
+// a cron schedule starts.
+func (c *cronSchedule) Skip(prev time.Time, t time.Time) time.Time {
+	t = t.In(prev.Location())
+
+	for back := time.Minute; ; back *= 2 {
+		from := t.Add(-back)
+		if !from.After(prev) {
+			return prev
+		}
+		if next := c.Next(from); !next.IsZero() && !next.After(t) {
+			return next
+		}
+	}
+}
+
+func (c *cronSchedule) Next(prev time.Time) time.Time {
+	loc := prev.Location()
+	t := prev.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows the usual cron rule: when both day of month and day of
+// week are restricted, a day matching either one is enough.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// phrases
+
+type unit int
+
+const (
+	minutes unit = iota
+	hours
+	days
+	weeks
+	months
+)
+
+type phraseSchedule struct {
+	interval int
+	unit     unit
+	weekdays []time.Weekday
+	monthDay int
+	hour     int
+	minute   int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var (
+	phraseAt       = regexp.MustCompile(`\s+at\s+(.+)$`)
+	phraseInterval = regexp.MustCompile(`^(\d+)\s+(.+)$`)
+	phraseMonthDay = regexp.MustCompile(`^(?:on\s+)?(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)?$`)
+	clockTime      = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+func parsePhrase(rule string) (*phraseSchedule, error) {
+	p := phraseSchedule{interval: 1, unit: days, hour: 9}
+
+	switch rule {
+	case "daily":
+		rule = "every day"
+	case "weekly":
+		rule = "every week"
+	case "monthly":
+		rule = "every month"
+	case "hourly":
+		rule = "every hour"
+	}
+
+	rule = strings.TrimSpace(strings.TrimPrefix(rule, "every"))
+
+	if match := phraseAt.FindStringSubmatch(rule); match != nil {
+		hour, minute, err := parseClock(match[1])
+		if err != nil {
+			return nil, err
+		}
+		p.hour, p.minute = hour, minute
+		rule = strings.TrimSpace(rule[:len(rule)-len(match[0])])
+	}
+
+	if match := phraseInterval.FindStringSubmatch(rule); match != nil {
+		interval, err := strconv.Atoi(match[1])
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", match[1])
+		}
+		p.interval = interval
+		rule = match[2]
+	}
+
+	head, tail, _ := strings.Cut(rule, " ")
+	tail = strings.TrimSpace(tail)
+
+	switch strings.TrimSuffix(head, "s") {
+	case "minute", "min":
+		p.unit = minutes
+	case "hour":
+		p.unit = hours
+	case "day":
+		p.unit = days
+	case "weekday":
+		p.unit = days
+		p.weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	case "weekend":
+		p.unit = days
+		p.weekdays = []time.Weekday{time.Saturday, time.Sunday}
+	case "week":
+		p.unit = weeks
+		if len(tail) > 0 {
+			weekdays, err := parseWeekdays(strings.TrimPrefix(tail, "on "))
+			if err != nil {
+				return nil, err
+			}
+			p.weekdays = weekdays
+			tail = ""
+		}
+	case "month":
+		p.unit = months
+		p.monthDay = 1
+		if len(tail) > 0 {
+			match := phraseMonthDay.FindStringSubmatch(tail)
+			if match == nil {
+				return nil, fmt.Errorf("could not understand %q", tail)
+			}
+			p.monthDay, _ = strconv.Atoi(match[1])
+			if p.monthDay < 1 || p.monthDay > 31 {
+				return nil, fmt.Errorf("invalid day of month %d", p.monthDay)
+			}
+			tail = ""
+		}
+	default:
+		weekdays, err := parseWeekdays(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.unit = weeks
+		p.weekdays = weekdays
+		tail = ""
+	}
+
+	if len(tail) > 0 {
+		return nil, fmt.Errorf("could not understand %q", tail)
+	}
+
+	if p.weekdays != nil && p.unit == days && p.interval != 1 {
+		return nil, fmt.Errorf("weekday rules can't have an interval")
+	}
+
+	return &p, nil
+}
+
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	s = strings.NewReplacer(",", " ", " and ", " ").Replace(s)
+
+	var weekdays []time.Weekday
+	for _, name := range strings.Fields(s) {
+		weekday, ok := weekdayNames[strings.TrimSuffix(name, "s")]
+		if !ok {
+			weekday, ok = weekdayNames[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		weekdays = append(weekdays, weekday)
+	}
+
+	if len(weekdays) == 0 {
+		return nil, fmt.Errorf("no days given")
+	}
+
+	return weekdays, nil
+}
+
+// parseClock understands "9am", "9:30 pm", "17:45", "noon" and "midnight".
+func parseClock(s string) (int, int, error) {
+	s = strings.TrimSpace(s)
+
+	switch s {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+
+	match := clockTime.FindStringSubmatch(s)
+	if match == nil {
+		return 0, 0, fmt.Errorf("could not understand time %q", s)
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute := 0
+	if len(match[2]) > 0 {
+		minute, _ = strconv.Atoi(match[2])
+	}
+
+	switch match[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid hour %d", hour)
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid hour %d", hour)
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	return hour, minute, nil
+}
+
+// First ignores the interval of calendar rules, so "every 2 weeks on Friday"
+// starts on the coming Friday rather than the one after.
+func (p *phraseSchedule) First(t time.Time) time.Time {
+	if p.unit == minutes || p.unit == hours {
+		return p.step(t)
+	}
+
+	first := *p
+	first.interval = 1
+	return first.step(t)
+}
+
+func (p *phraseSchedule) Next(prev time.Time) time.Time {
+	return p.step(prev)
+}
+
+// Skip jumps whole intervals of minute and hour rules. The others fire at
+// most once a day, few enough to step through.
+func (p *phraseSchedule) Skip(prev time.Time, t time.Time) time.Time {
+	var interval time.Duration
+	switch p.unit {
+	case minutes:
+		interval = time.Duration(p.interval) * time.Minute
+	case hours:
+		interval = time.Duration(p.interval) * time.Hour
+	default:
+		return prev
+	}
+
+	if !t.After(prev) {
+		return prev
+	}
+	return prev.Add(t.Sub(prev) / interval * interval)
+}
+
+func (p *phraseSchedule) step(after time.Time) time.Time {
+	loc := after.Location()
+
+	switch p.unit {
+	case minutes:
+		return after.Truncate(time.Minute).Add(time.Duration(p.interval) * time.Minute)
+	case hours:
+		return after.Truncate(time.Minute).Add(time.Duration(p.interval) * time.Hour)
+	case days:
+		t := time.Date(after.Year(), after.Month(), after.Day(), p.hour, p.minute, 0, 0, loc)
+		if !t.After(after) {
+			t = t.AddDate(0, 0, p.interval)
+		}
+		for p.weekdays != nil && !p.onWeekday(t) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t
+	case weeks:
+		weekdays := p.weekdays
+		if weekdays == nil {
+			weekdays = []time.Weekday{after.Weekday()}
+		}
+
+		// look for a later slot in the same week before skipping ahead
+		weekStart := time.Date(after.Year(), after.Month(), after.Day()-int(after.Weekday()), p.hour, p.minute, 0, 0, loc)
+		for _, offset := range sortedWeekdays(weekdays) {
+			t := weekStart.AddDate(0, 0, int(offset))
+			if t.After(after) {
+				return t
+			}
+		}
+
+		weekStart = weekStart.AddDate(0, 0, 7*p.interval)
+		return weekStart.AddDate(0, 0, int(sortedWeekdays(weekdays)[0]))
+	case months:
+		t := monthDate(after.Year(), after.Month(), p.monthDay, p.hour, p.minute, loc)
+		if !t.After(after) {
+			t = monthDate(after.Year(), after.Month()+time.Month(p.interval), p.monthDay, p.hour, p.minute, loc)
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (p *phraseSchedule) onWeekday(t time.Time) bool {
+	for _, weekday := range p.weekdays {
+		if t.Weekday() == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedWeekdays(weekdays []time.Weekday) []time.Weekday {
+	var sorted []time.Weekday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		for _, weekday := range weekdays {
+			if weekday == day {
+				sorted = append(sorted, day)
+				break
+			}
+		}
+	}
+	return sorted
+}
+
+// monthDate clamps day to the last day of the month, so "every month on the
+// 31st" still fires in February.
+func monthDate(year int, month time.Month, day int, hour int, minute int, loc *time.Location) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month, day, hour, minute, 0, 0, loc)
+}
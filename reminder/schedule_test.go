@@ -0,0 +1,144 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestScheduleFirst(t *testing.T) {
+	// 2022-10-26 is a Wednesday
+	now := date("2022-10-26 10:00")
+
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"every day at 9am", "2022-10-27 09:00"},
+		{"every day at noon", "2022-10-26 12:00"},
+		{"daily", "2022-10-27 09:00"},
+		{"every weekday at 9:30am", "2022-10-27 09:30"},
+		{"every weekend", "2022-10-29 09:00"},
+		{"every friday at 5pm", "2022-10-28 17:00"},
+		{"every monday and wednesday at 17:45", "2022-10-26 17:45"},
+		{"every 2 weeks on friday", "2022-10-28 09:00"},
+		{"every month on the 1st at 8am", "2022-11-01 08:00"},
+		{"every month on the 31st", "2022-10-31 09:00"},
+		{"every 15 minutes", "2022-10-26 10:15"},
+		{"every hour", "2022-10-26 11:00"},
+		{"0 9 1 * *", "2022-11-01 09:00"},
+		{"*/20 * * * *", "2022-10-26 10:20"},
+		{"30 8 * * mon-fri", "2022-10-27 08:30"},
+		{"0 0 13 * fri", "2022-10-28 00:00"},
+		{"0 12 * jan *", "2023-01-01 12:00"},
+	}
+
+	for _, test := range tests {
+		sched, err := parseRule(test.rule)
+		if err != nil {
+			t.Errorf("parseRule(%q) error: %s", test.rule, err)
+			continue
+		}
+
+		if got := sched.First(now); !got.Equal(date(test.want)) {
+			t.Errorf("parseRule(%q).First(%s) = %s, want %s", test.rule, now, got, test.want)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		rule string
+		prev string
+		want string
+	}{
+		{"every weekday at 9am", "2022-10-28 09:00", "2022-10-31 09:00"},
+		{"every 2 weeks on friday", "2022-10-28 09:00", "2022-11-11 09:00"},
+		{"every 2 weeks on tuesday and friday", "2022-10-25 09:00", "2022-10-28 09:00"},
+		{"every 2 weeks on tuesday and friday", "2022-10-28 09:00", "2022-11-08 09:00"},
+		{"every month on the 31st", "2023-01-31 09:00", "2023-02-28 09:00"},
+		{"every 3 days at 6pm", "2022-10-26 18:00", "2022-10-29 18:00"},
+		{"0 9 1 * *", "2022-11-01 09:00", "2022-12-01 09:00"},
+	}
+
+	for _, test := range tests {
+		sched, err := parseRule(test.rule)
+		if err != nil {
+			t.Errorf("parseRule(%q) error: %s", test.rule, err)
+			continue
+		}
+
+		if got := sched.Next(date(test.prev)); !got.Equal(date(test.want)) {
+			t.Errorf("parseRule(%q).Next(%s) = %s, want %s", test.rule, test.prev, got, test.want)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	rules := []string{
+		"",
+		"every fortnight",
+		"every day at 25pm",
+		"every month on the 40th",
+		"0 9 * *",
+		"61 * * * *",
+		"* * * * funday",
+	}
+
+	for _, rule := range rules {
+		if _, err := parseRule(rule); err == nil {
+			t.Errorf("parseRule(%q) expected an error", rule)
+		}
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	e := event{
+		Message: "standup",
+		Next:    date("2022-10-24 09:00"),
+		Rule:    "every weekday at 9am",
+	}
+
+	if !e.reschedule(date("2022-10-26 10:00")) {
+		t.Fatal("recurring event was not kept")
+	}
+
+	if !e.Next.Equal(date("2022-10-27 09:00")) {
+		t.Errorf("reschedule skipped to %s, want 2022-10-27 09:00", e.Next)
+	}
+
+	once := event{Message: "once", Next: date("2022-10-24 09:00")}
+	if once.reschedule(date("2022-10-26 10:00")) {
+		t.Error("one-off event was kept")
+	}
+}
+
+func TestRescheduleFollowsZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	// daylight saving ends on 2022-11-06, so 9am moves from 13:00 to 14:00 UTC
+	e := event{
+		Message: "standup",
+		Next:    date("2022-11-04 13:00"),
+		Rule:    "every weekday at 9am",
+		Zone:    "America/New_York",
+	}
+
+	if !e.reschedule(date("2022-11-04 13:00")) {
+		t.Fatal("recurring event was not kept")
+	}
+
+	if want := time.Date(2022, 11, 7, 9, 0, 0, 0, newYork); !e.Next.Equal(want) {
+		t.Errorf("reschedule moved to %s, want %s", e.Next, want)
+	}
+}
@@ -0,0 +1,115 @@
+package reminder
+
+import (
+	"container/heap"
+	"time"
+)
+
+// clock is the source of time for the scheduler so tests can drive it.
+type clock interface {
+	Now() time.Time
+	// At returns a channel that receives once the clock reaches t, and a
+	// function that releases the timer early.
+	At(t time.Time) (<-chan time.Time, func())
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) At(t time.Time) (<-chan time.Time, func()) {
+	timer := time.NewTimer(time.Until(t))
+	return timer.C, func() { timer.Stop() }
+}
+
+// eventHeap orders events by their next fire time.
+type eventHeap []*event
+
+func (h eventHeap) Len() int           { return len(h) }
+func (h eventHeap) Less(i, j int) bool { return h[i].Next.Before(h[j].Next) }
+
+func (h eventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *eventHeap) Push(x any) {
+	e := x.(*event)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *eventHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
+
+// wake interrupts the scheduler's sleep after the set of events changed.
+var wake = make(chan struct{}, 1)
+
+func notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// run fires due events and sleeps until the earliest remaining one, or until
+// notify is called. Events that are overdue are handled by the catch up
+// policy. save is only called after events fired. It returns when done is
+// closed.
+func run(c clock, send func(delivery), save func(), done <-chan struct{}) {
+	for {
+		mu.Lock()
+
+		now := c.Now()
+
+		var fired []occurrence
+		for len(events) > 0 && !events[0].Next.After(now) {
+			e := events[0]
+			for _, at := range e.occurrences(now, catchUp.Replay) {
+				fired = append(fired, occurrence{*e, at})
+			}
+			if e.reschedule(now) {
+				heap.Fix(&events, 0)
+			} else {
+				heap.Pop(&events)
+			}
+		}
+
+		if len(fired) > 0 {
+			save()
+		}
+
+		var timer <-chan time.Time
+		stop := func() {}
+		if len(events) > 0 {
+			timer, stop = c.At(events[0].Next)
+		}
+
+		deliveries := catchUp.deliveries(fired, now)
+
+		mu.Unlock()
+
+		for _, d := range deliveries {
+			send(d)
+		}
+
+		select {
+		case <-timer:
+		case <-wake:
+		case <-done:
+			stop()
+			return
+		}
+
+		stop()
+	}
+}
@@ -0,0 +1,189 @@
+package reminder
+
+import (
+	"container/heap"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) At(t time.Time) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &fakeTimer{at: t, ch: make(chan time.Time, 1)}
+	if !t.After(c.now) {
+		timer.ch <- c.now
+	} else {
+		c.timers = append(c.timers, timer)
+	}
+
+	return timer.ch, func() {}
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if !timer.at.After(c.now) {
+			timer.ch <- c.now
+		} else {
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+}
+
+type harness struct {
+	clock *fakeClock
+	fired chan delivery
+	saves chan struct{}
+	done  chan struct{}
+}
+
+func startScheduler(t *testing.T, now time.Time, initial ...*event) *harness {
+	mu.Lock()
+	events = nil
+	for _, e := range initial {
+		heap.Push(&events, e)
+	}
+	mu.Unlock()
+
+	h := &harness{
+		clock: &fakeClock{now: now},
+		fired: make(chan delivery, 16),
+		saves: make(chan struct{}, 16),
+		done:  make(chan struct{}),
+	}
+
+	go run(h.clock, func(d delivery) { h.fired <- d }, func() { h.saves <- struct{}{} }, h.done)
+	t.Cleanup(func() { close(h.done) })
+
+	return h
+}
+
+func (h *harness) expectFire(t *testing.T, message string) {
+	t.Helper()
+	select {
+	case d := <-h.fired:
+		if d.message != message {
+			t.Errorf("fired %q, want %q", d.message, message)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%q never fired", message)
+	}
+	select {
+	case <-h.saves:
+	case <-time.After(time.Second):
+		t.Fatalf("events were not saved after %q fired", message)
+	}
+}
+
+func (h *harness) expectQuiet(t *testing.T) {
+	t.Helper()
+	select {
+	case d := <-h.fired:
+		t.Errorf("unexpected fire of %q", d.message)
+	case <-h.saves:
+		t.Error("unexpected save")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSchedulerFiresInOrder(t *testing.T) {
+	now := date("2022-10-26 10:00")
+	h := startScheduler(t, now,
+		&event{Message: "second", Next: now.Add(2 * time.Hour)},
+		&event{Message: "first", Next: now.Add(time.Hour)},
+	)
+
+	h.expectQuiet(t)
+
+	h.clock.Advance(time.Hour)
+	h.expectFire(t, "first")
+	h.expectQuiet(t)
+
+	h.clock.Advance(time.Hour)
+	h.expectFire(t, "second")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 0 {
+		t.Errorf("%d events left after firing", len(events))
+	}
+}
+
+func TestSchedulerWakesOnNewEvent(t *testing.T) {
+	now := date("2022-10-26 10:00")
+	h := startScheduler(t, now, &event{Message: "later", Next: now.Add(24 * time.Hour)})
+
+	h.expectQuiet(t)
+
+	mu.Lock()
+	heap.Push(&events, &event{Message: "sooner", Next: now.Add(time.Minute)})
+	mu.Unlock()
+	notify()
+
+	// a wake without due events must not persist anything
+	h.expectQuiet(t)
+
+	h.clock.Advance(time.Minute)
+	h.expectFire(t, "sooner")
+}
+
+func TestSchedulerKeepsRecurringEvents(t *testing.T) {
+	now := date("2022-10-26 08:00")
+	h := startScheduler(t, now, &event{Message: "standup", Next: date("2022-10-26 09:00"), Rule: "every weekday at 9am"})
+
+	h.clock.Advance(time.Hour)
+	h.expectFire(t, "standup")
+
+	mu.Lock()
+	if len(events) != 1 || !events[0].Next.Equal(date("2022-10-27 09:00")) {
+		t.Errorf("recurring event was not rescheduled: %+v", events)
+	}
+	mu.Unlock()
+
+	h.clock.Advance(24 * time.Hour)
+	h.expectFire(t, "standup")
+}
+
+func TestEventHeapRemove(t *testing.T) {
+	now := date("2022-10-26 10:00")
+	var h eventHeap
+	for _, offset := range []int{5, 1, 4, 2, 3} {
+		heap.Push(&h, &event{Message: "m", Next: now.Add(time.Duration(offset) * time.Hour)})
+	}
+
+	heap.Remove(&h, h[2].index)
+
+	last := time.Time{}
+	for h.Len() > 0 {
+		e := heap.Pop(&h).(*event)
+		if e.Next.Before(last) {
+			t.Fatalf("heap out of order: %s before %s", e.Next, last)
+		}
+		last = e.Next
+	}
+}
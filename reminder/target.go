@@ -0,0 +1,119 @@
+package reminder
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var mention = regexp.MustCompile(`<@&\d+>|<@!?\d+>|@everyone|@here`)
+
+// parsePing splits a list of role and user mentions such as
+// "<@&123> <@456> @here" and rejects anything else.
+func parsePing(ping string) ([]string, error) {
+	mentions := mention.FindAllString(ping, -1)
+
+	if rest := strings.TrimSpace(strings.NewReplacer(",", "", " ", "").Replace(mention.ReplaceAllString(ping, ""))); len(rest) > 0 {
+		return nil, fmt.Errorf("I can only ping roles, users, @everyone or @here, not `%s`", rest)
+	}
+
+	// <@!id> is the old nickname form of <@id>
+	for index, m := range mentions {
+		mentions[index] = strings.Replace(m, "<@!", "<@", 1)
+	}
+
+	return mentions, nil
+}
+
+// checkPing reports whether someone with perms may ping every mention in
+// ping. @everyone, @here and roles that aren't mentionable need the Mention
+// Everyone permission, just like when typing them in the channel.
+func checkPing(ping []string, perms int64, role func(id string) (*discordgo.Role, error)) error {
+	if perms&discordgo.PermissionAdministrator != 0 || perms&discordgo.PermissionMentionEveryone != 0 {
+		return nil
+	}
+
+	for _, m := range ping {
+		if m == "@everyone" || m == "@here" {
+			return fmt.Errorf("you need the Mention Everyone permission to ping %s", m)
+		}
+
+		if strings.HasPrefix(m, "<@&") {
+			r, err := role(strings.TrimSuffix(strings.TrimPrefix(m, "<@&"), ">"))
+			if err != nil {
+				return fmt.Errorf("I couldn't find the role %s", m)
+			}
+			if !r.Mentionable {
+				return fmt.Errorf("you need the Mention Everyone permission to ping %s", m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// allowedMentions only lets the pings checked at creation through, so a
+// reminder's message can't sneak in a role ping or an @everyone. Users
+// mentioned in the message are still pinged.
+func allowedMentions(ping []string) *discordgo.MessageAllowedMentions {
+	allowed := discordgo.MessageAllowedMentions{
+		Parse: []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers},
+	}
+
+	for _, m := range ping {
+		switch {
+		case m == "@everyone" || m == "@here":
+			allowed.Parse = append(allowed.Parse, discordgo.AllowedMentionTypeEveryone)
+		case strings.HasPrefix(m, "<@&"):
+			allowed.Roles = append(allowed.Roles, strings.TrimSuffix(strings.TrimPrefix(m, "<@&"), ">"))
+		}
+	}
+
+	return &allowed
+}
+
+// target describes where a reminder goes, for listings.
+func (e *event) target() string {
+	var target string
+
+	if e.Dm {
+		target = fmt.Sprintf("DM <@%s>", e.AuthorId)
+	}
+
+	if len(e.Ping) > 0 {
+		target = strings.TrimSpace(target + " " + strings.Join(e.Ping, " "))
+	}
+
+	return target
+}
+
+// deliver sends d to its channel or to the author's DMs.
+func deliver(s *discordgo.Session, d delivery) {
+	channelId := d.channelId
+
+	if len(d.dmUserId) > 0 {
+		channel, err := s.UserChannelCreate(d.dmUserId)
+		if err != nil {
+			log.Printf("could not open a DM with %s: %s", d.dmUserId, err)
+			return
+		}
+		channelId = channel.ID
+	}
+
+	content := d.message
+	if len(d.ping) > 0 {
+		content = strings.Join(d.ping, " ") + " " + content
+	}
+
+	_, err := s.ChannelMessageSendComplex(channelId, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: allowedMentions(d.ping),
+	})
+
+	if err != nil {
+		log.Printf("could not send reminder to %s: %s", channelId, err)
+	}
+}
@@ -0,0 +1,73 @@
+package reminder
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParsePing(t *testing.T) {
+	ping, err := parsePing("<@&10>, <@!20> <@30> @here")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"<@&10>", "<@20>", "<@30>", "@here"}
+	if fmt.Sprint(ping) != fmt.Sprint(want) {
+		t.Errorf("parsePing = %q, want %q", ping, want)
+	}
+
+	if _, err := parsePing("<@&10> everybody"); err == nil {
+		t.Error("parsePing accepted plain text")
+	}
+}
+
+func TestCheckPing(t *testing.T) {
+	roles := map[string]*discordgo.Role{
+		"10": {ID: "10", Mentionable: true},
+		"11": {ID: "11", Mentionable: false},
+	}
+	role := func(id string) (*discordgo.Role, error) {
+		if r, ok := roles[id]; ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("no role %s", id)
+	}
+
+	tests := []struct {
+		ping  []string
+		perms int64
+		ok    bool
+	}{
+		{[]string{"<@20>"}, 0, true},
+		{[]string{"<@&10>"}, 0, true},
+		{[]string{"<@&11>"}, 0, false},
+		{[]string{"<@&11>"}, discordgo.PermissionMentionEveryone, true},
+		{[]string{"<@&12>"}, 0, false},
+		{[]string{"@everyone"}, discordgo.PermissionSendMessages, false},
+		{[]string{"@here"}, discordgo.PermissionMentionEveryone, true},
+		{[]string{"@everyone"}, discordgo.PermissionAdministrator, true},
+	}
+
+	for _, test := range tests {
+		err := checkPing(test.ping, test.perms, role)
+		if (err == nil) != test.ok {
+			t.Errorf("checkPing(%q, %d) = %v, want ok %v", test.ping, test.perms, err, test.ok)
+		}
+	}
+}
+
+func TestAllowedMentions(t *testing.T) {
+	allowed := allowedMentions([]string{"<@&10>", "<@20>"})
+
+	if len(allowed.Roles) != 1 || allowed.Roles[0] != "10" {
+		t.Errorf("allowed roles = %q, want [10]", allowed.Roles)
+	}
+
+	for _, parse := range allowed.Parse {
+		if parse == discordgo.AllowedMentionTypeEveryone || parse == discordgo.AllowedMentionTypeRoles {
+			t.Errorf("allowed mentions parse %q without being asked to", parse)
+		}
+	}
+}
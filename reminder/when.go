@@ -0,0 +1,268 @@
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcampbell/fuzzytime"
+)
+
+// dayParts are the default times for words like "tomorrow morning".
+var dayParts = map[string]int{
+	"morning":   9,
+	"afternoon": 15,
+	"evening":   18,
+	"tonight":   20,
+	"night":     21,
+}
+
+var (
+	durationPart = regexp.MustCompile(`^(\d+|an?)\s*([a-z]+)`)
+	durationSep  = regexp.MustCompile(`^(?:\s|,|and\b)+`)
+)
+
+// maxYears is how far ahead an offset like "in 3 weeks" may reach.
+const maxYears = 10
+
+var errTooFar = fmt.Errorf("that's more than %d years away", maxYears)
+
+// parseWhen resolves a time expression against now in loc. It understands
+// relative offsets ("in 20 minutes", "in 1h30m"), days ("tomorrow",
+// "next tuesday"), day parts ("tonight", "friday afternoon") and clock times
+// ("at noon", "9:30pm"), and falls back to absolute dates such as
+// "2022-10-29 08:43 -0400". Times without an offset are taken to be in loc.
+func parseWhen(when string, now time.Time, loc *time.Location) (time.Time, error) {
+	when = strings.ToLower(strings.TrimSpace(when))
+	now = now.In(loc)
+
+	if len(when) == 0 {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+
+	var t time.Time
+	var err error
+
+	if strings.HasPrefix(when, "in ") {
+		t, err = parseOffset(strings.TrimPrefix(when, "in "), now)
+		if errors.Is(err, errTooFar) {
+			return time.Time{}, err
+		}
+	}
+
+	if t.IsZero() {
+		if t, err = parseRelative(when, now); err != nil {
+			t, err = parseAbsolute(when, loc)
+		}
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("%s is in the past", t.Format(listFormat))
+	}
+
+	return t, nil
+}
+
+// parseOffset handles "20 minutes", "2h", "1h30m", "an hour and 15 minutes"
+// and "3 days".
+func parseOffset(s string, now time.Time) (time.Time, error) {
+	t := now
+	limit := now.AddDate(maxYears, 0, 0)
+	s = strings.TrimSpace(s)
+
+	if len(s) == 0 {
+		return time.Time{}, fmt.Errorf("missing duration")
+	}
+
+	for len(s) > 0 {
+		match := durationPart.FindStringSubmatch(s)
+		if match == nil {
+			return time.Time{}, fmt.Errorf("could not understand %q", s)
+		}
+
+		n := 1
+		if match[1] != "a" && match[1] != "an" {
+			var err error
+			if n, err = strconv.Atoi(match[1]); err != nil {
+				// The pattern only lets digits through, so the number is too big.
+				return time.Time{}, errTooFar
+			}
+		}
+
+		var unit time.Duration
+		var years, months, days int
+		switch match[2] {
+		case "s", "sec", "secs", "second", "seconds":
+			unit = time.Second
+		case "m", "min", "mins", "minute", "minutes":
+			unit = time.Minute
+		case "h", "hr", "hrs", "hour", "hours":
+			unit = time.Hour
+		case "d", "day", "days":
+			days = 1
+		case "w", "wk", "wks", "week", "weeks":
+			days = 7
+		case "mo", "month", "months":
+			months = 1
+		case "y", "yr", "year", "years":
+			years = 1
+		default:
+			return time.Time{}, fmt.Errorf("unknown unit %q", match[2])
+		}
+
+		// Checking each part against the limit before adding it also keeps
+		// the arithmetic from overflowing.
+		if unit > 0 {
+			if time.Duration(n) > limit.Sub(t)/unit {
+				return time.Time{}, errTooFar
+			}
+			t = t.Add(time.Duration(n) * unit)
+		} else {
+			if n > maxYears*366 {
+				return time.Time{}, errTooFar
+			}
+			t = t.AddDate(n*years, n*months, n*days)
+		}
+		if t.After(limit) {
+			return time.Time{}, errTooFar
+		}
+
+		s = s[len(match[0]):]
+		s = s[len(durationSep.FindString(s)):]
+	}
+
+	return t, nil
+}
+
+// parseRelative handles a day ("today", "tomorrow", "next friday") and/or a
+// time of day ("noon", "9am", "evening") in any order.
+func parseRelative(s string, now time.Time) (time.Time, error) {
+	words := strings.Fields(strings.ReplaceAll(s, ",", " "))
+
+	days := -1
+	weekday := time.Weekday(-1)
+	next := false
+	hour, minute := -1, 0
+	defaultHour := -1
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		switch {
+		case word == "at" || word == "on" || word == "the" || word == "in" || word == "this":
+		case word == "next":
+			next = true
+		case word == "today":
+			days = 0
+		case word == "tonight":
+			days = 0
+			defaultHour = dayParts[word]
+		case word == "tomorrow":
+			if days == -2 {
+				days = 2
+			} else {
+				days = 1
+			}
+		case word == "day" && i+1 < len(words) && words[i+1] == "after":
+			days = -2
+			i++
+		case word == "week" && next:
+			days = 7
+		case word == "noon" || word == "midday":
+			hour, minute = 12, 0
+		case word == "midnight":
+			hour, minute = 0, 0
+		default:
+			if part, ok := dayParts[word]; ok {
+				defaultHour = part
+				continue
+			}
+
+			if day, ok := weekdayNames[word]; ok {
+				weekday = day
+				continue
+			}
+
+			clock := word
+			if i+1 < len(words) && (words[i+1] == "am" || words[i+1] == "pm") {
+				clock += words[i+1]
+				i++
+			}
+
+			h, m, err := parseClock(clock)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("could not understand %q", word)
+			}
+			hour, minute = h, m
+		}
+	}
+
+	if days == -2 {
+		return time.Time{}, fmt.Errorf("day after what?")
+	}
+
+	if hour < 0 {
+		if days < 0 && weekday < 0 && defaultHour < 0 {
+			return time.Time{}, fmt.Errorf("no day or time given")
+		}
+		hour, minute = defaultHour, 0
+		if hour < 0 {
+			hour = dayParts["morning"]
+		}
+	}
+
+	bare := days < 0
+	if weekday >= 0 {
+		days = int(weekday-now.Weekday()+7) % 7
+		if days == 0 && next {
+			days = 7
+		}
+	} else if days < 0 {
+		days = 0
+	}
+
+	t := time.Date(now.Year(), now.Month(), now.Day()+days, hour, minute, 0, 0, now.Location())
+
+	// a bare time or weekday means the next one, not one earlier today
+	if !t.After(now) && days == 0 {
+		if weekday >= 0 {
+			t = t.AddDate(0, 0, 7)
+		} else if bare {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+
+	return t, nil
+}
+
+// parseAbsolute extracts a full date and time, using loc when the input has
+// no offset of its own.
+func parseAbsolute(s string, loc *time.Location) (time.Time, error) {
+	extracted, _, err := fuzzytime.Extract(s)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse when")
+	}
+
+	if !extracted.HasFullDate() {
+		return time.Time{}, fmt.Errorf("could not find a date")
+	}
+
+	if extracted.HasTZOffset() {
+		loc = time.FixedZone("", extracted.TZOffset())
+	}
+
+	hour, minute := 9, 0
+	if extracted.HasHour() {
+		hour, minute = extracted.Hour(), extracted.Minute()
+	}
+
+	return time.Date(extracted.Year(), time.Month(extracted.Month()), extracted.Day(), hour, minute, 0, 0, loc), nil
+}
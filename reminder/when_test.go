@@ -0,0 +1,118 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// 2022-10-26 is a Wednesday
+	now := date("2022-10-26 10:00")
+
+	tests := []struct {
+		when string
+		want string
+	}{
+		{"in 20 minutes", "2022-10-26 10:20"},
+		{"in 2h", "2022-10-26 12:00"},
+		{"in 1h30m", "2022-10-26 11:30"},
+		{"in an hour and 15 minutes", "2022-10-26 11:15"},
+		{"in 3 days", "2022-10-29 10:00"},
+		{"in a week", "2022-11-02 10:00"},
+		{"in 10 years", "2032-10-26 10:00"},
+		{"In 2 Hours", "2022-10-26 12:00"},
+		{"tomorrow", "2022-10-27 09:00"},
+		{"tomorrow at noon", "2022-10-27 12:00"},
+		{"tomorrow 9am", "2022-10-27 09:00"},
+		{"tomorrow 9 pm", "2022-10-27 21:00"},
+		{"tomorrow evening", "2022-10-27 18:00"},
+		{"day after tomorrow", "2022-10-28 09:00"},
+		{"today at 5pm", "2022-10-26 17:00"},
+		{"tonight", "2022-10-26 20:00"},
+		{"at 3:30pm", "2022-10-26 15:30"},
+		{"8am", "2022-10-27 08:00"},
+		{"noon", "2022-10-26 12:00"},
+		{"midnight", "2022-10-27 00:00"},
+		{"this afternoon", "2022-10-26 15:00"},
+		{"in the morning", "2022-10-27 09:00"},
+		{"friday", "2022-10-28 09:00"},
+		{"next tuesday", "2022-11-01 09:00"},
+		{"next wednesday", "2022-11-02 09:00"},
+		{"wednesday at 9am", "2022-11-02 09:00"},
+		{"wednesday at 11am", "2022-10-26 11:00"},
+		{"on friday, 4:15pm", "2022-10-28 16:15"},
+		{"fri afternoon", "2022-10-28 15:00"},
+		{"next week", "2022-11-02 09:00"},
+		{"2022-10-29 08:43", "2022-10-29 08:43"},
+		{"2022-11-05", "2022-11-05 09:00"},
+	}
+
+	for _, test := range tests {
+		got, err := parseWhen(test.when, now, time.UTC)
+		if err != nil {
+			t.Errorf("parseWhen(%q) error: %s", test.when, err)
+			continue
+		}
+
+		if !got.Equal(date(test.want)) {
+			t.Errorf("parseWhen(%q) = %s, want %s", test.when, got, test.want)
+		}
+	}
+}
+
+func TestParseWhenLocation(t *testing.T) {
+	now := date("2022-10-26 10:00")
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	tests := []struct {
+		when string
+		want string
+	}{
+		// 10:00 UTC is 06:00 in New York
+		{"at 9am", "2022-10-26 13:00"},
+		{"tomorrow at noon", "2022-10-27 16:00"},
+		// the day after daylight saving ends
+		{"2022-11-07 09:00", "2022-11-07 14:00"},
+		{"2022-10-29 08:43 -0400", "2022-10-29 12:43"},
+		{"2022-10-29 08:43 +0100", "2022-10-29 07:43"},
+	}
+
+	for _, test := range tests {
+		got, err := parseWhen(test.when, now, newYork)
+		if err != nil {
+			t.Errorf("parseWhen(%q) error: %s", test.when, err)
+			continue
+		}
+
+		if !got.Equal(date(test.want)) {
+			t.Errorf("parseWhen(%q) = %s, want %s UTC", test.when, got, test.want)
+		}
+	}
+}
+
+func TestParseWhenErrors(t *testing.T) {
+	now := date("2022-10-26 10:00")
+
+	inputs := []string{
+		"",
+		"whenever",
+		"in 5 bananas",
+		"today at 9am",
+		"2021-01-01 10:00",
+		"tomorrow at 25:00",
+		"in 99999999999999999999 minutes",
+		"in 9223372036 seconds",
+		"in 3000000 hours",
+		"in 11 years",
+		"in 9 years and 13 months",
+	}
+
+	for _, input := range inputs {
+		if got, err := parseWhen(input, now, time.UTC); err == nil {
+			t.Errorf("parseWhen(%q) = %s, expected an error", input, got)
+		}
+	}
+}
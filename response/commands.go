@@ -0,0 +1,183 @@
+package response
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+	"rawrippers.com/grumpy-daemon/utils"
+)
+
+var modeChoices = []*discordgo.ApplicationCommandOptionChoice{
+	{Name: "word", Value: ModeWord},
+	{Name: "substring", Value: ModeSubstring},
+	{Name: "regex", Value: ModeRegex},
+	{Name: "glob", Value: ModeGlob},
+	{Name: "exact", Value: ModeExact},
+}
+
+func init() {
+	command.Register(
+		&command.Command{
+			Name:        "response",
+			Description: "set a channel response",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "message",
+					Description: "message to post",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "search",
+					Description: "e.g. search that will trigger response",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "how to match the search, whole words by default",
+					Required:    false,
+					Choices:     modeChoices,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "cooldown",
+					Description: "stay quiet in the channel this long after responding, e.g. 5m",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "user_cooldown",
+					Description: "ignore the same person for this long after responding, e.g. 1h",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "probability",
+					Description: "percent chance of responding to a match",
+					Required:    false,
+					MaxValue:    100,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "max_per_hour",
+					Description: "most times to respond in an hour",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "scope",
+					Description: "where it applies, this channel by default",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "this channel", Value: utils.ScopeChannel},
+						{Name: "this category", Value: utils.ScopeCategory},
+						{Name: "the whole server", Value: utils.ScopeGuild},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "attachment",
+					Description: "image or file to send with the response",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "title",
+					Description: "title of an embed to send with the response",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "color",
+					Description: "color of the embed, e.g. #ff8800",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "image_url",
+					Description: "link to an image to show in the embed",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "footer",
+					Description: "footer of the embed",
+					Required:    false,
+				},
+			},
+			Capability: permission.Create,
+			Handler:    SetResponse,
+		},
+		&command.Command{
+			Name:        "preview_response",
+			Description: "try out a response message on some sample text",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "message",
+					Description: "message to post, with {user}, {match}, {a|b} and so on",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "text",
+					Description: "sample message that triggers it",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "search",
+					Description: "search to match against the text, the whole text by default",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "how to match the search, whole words by default",
+					Required:    false,
+					Choices:     modeChoices,
+				},
+			},
+			Handler: PreviewResponse,
+		},
+		&command.Command{
+			Name:        "list_responses",
+			Description: "list all channel responses",
+			Handler:     ListResponses,
+		},
+		&command.Command{
+			Name:        "delete_response",
+			Description: "delete a response",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "search",
+					Description:  "message to delete (by search)",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+			Capability:   permission.DeleteOwn,
+			Handler:      DeleteResponse,
+			Autocomplete: DeleteResponseAutocomplete,
+		},
+		&command.Command{
+			Name:        "optout_response",
+			Description: "turn a category or server response off in this channel, or back on",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "search",
+					Description: "response to turn off (by search)",
+					Required:    true,
+				},
+			},
+			Capability: permission.DeleteAny,
+			Handler:    OptOutResponse,
+		},
+	)
+
+	command.RegisterComponent("list_responses", ListPages, ListResponsesPage)
+}
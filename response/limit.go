@@ -0,0 +1,167 @@
+package response
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCooldown bounds the cooldowns that can be set.
+const maxCooldown = 24 * time.Hour
+
+// limiter remembers when responses last fired, to enforce their cooldowns
+// and hourly limits. It is only kept in memory, so a restart resets it.
+type limiter struct {
+	mu          sync.Mutex
+	lastChannel map[*response]time.Time
+	lastUser    map[userFire]time.Time
+	// fires holds when each response fired in the last hour.
+	fires map[*response][]time.Time
+}
+
+type userFire struct {
+	r      *response
+	userId string
+}
+
+var limits = newLimiter()
+
+func newLimiter() *limiter {
+	return &limiter{
+		lastChannel: make(map[*response]time.Time),
+		lastUser:    make(map[userFire]time.Time),
+		fires:       make(map[*response][]time.Time),
+	}
+}
+
+// allow reports whether r should fire for a message from userId, and if so
+// counts it as fired. roll returns a number from 0 to 99.
+func (l *limiter) allow(r *response, userId string, now time.Time, roll func() int) bool {
+	if !r.limited() {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.ChannelCooldown > 0 && now.Sub(l.lastChannel[r]) < r.ChannelCooldown {
+		return false
+	}
+
+	key := userFire{r, userId}
+	if r.UserCooldown > 0 && now.Sub(l.lastUser[key]) < r.UserCooldown {
+		return false
+	}
+
+	recent := l.fires[r]
+	for len(recent) > 0 && now.Sub(recent[0]) >= time.Hour {
+		recent = recent[1:]
+	}
+	l.fires[r] = recent
+
+	if r.MaxPerHour > 0 && len(recent) >= r.MaxPerHour {
+		return false
+	}
+
+	if r.Probability > 0 && r.Probability < 100 && roll() >= r.Probability {
+		return false
+	}
+
+	if r.ChannelCooldown > 0 {
+		l.lastChannel[r] = now
+	}
+	if r.UserCooldown > 0 {
+		l.sweep(now)
+		l.lastUser[key] = now
+	}
+	if r.MaxPerHour > 0 {
+		l.fires[r] = append(recent, now)
+	}
+
+	return true
+}
+
+// sweep forgets user cooldowns that are over, so the map doesn't grow with
+// every user who ever triggered a response.
+func (l *limiter) sweep(now time.Time) {
+	if len(l.lastUser) < 1000 {
+		return
+	}
+
+	for key, last := range l.lastUser {
+		if now.Sub(last) >= key.r.UserCooldown {
+			delete(l.lastUser, key)
+		}
+	}
+}
+
+// forget drops the state of a deleted response.
+func (l *limiter) forget(r *response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.lastChannel, r)
+	delete(l.fires, r)
+	for key := range l.lastUser {
+		if key.r == r {
+			delete(l.lastUser, key)
+		}
+	}
+}
+
+func roll() int {
+	return rand.Intn(100)
+}
+
+func (r *response) limited() bool {
+	return r.ChannelCooldown > 0 || r.UserCooldown > 0 || r.MaxPerHour > 0 || (r.Probability > 0 && r.Probability < 100)
+}
+
+// limitText describes r's limits for /list_responses, or is empty if it has
+// none.
+func (r *response) limitText() string {
+	var parts []string
+
+	if r.ChannelCooldown > 0 {
+		parts = append(parts, fmt.Sprintf("cooldown %s", shortDuration(r.ChannelCooldown)))
+	}
+	if r.UserCooldown > 0 {
+		parts = append(parts, fmt.Sprintf("%s per user", shortDuration(r.UserCooldown)))
+	}
+	if r.Probability > 0 && r.Probability < 100 {
+		parts = append(parts, fmt.Sprintf("%d%%", r.Probability))
+	}
+	if r.MaxPerHour > 0 {
+		parts = append(parts, fmt.Sprintf("%d/hour", r.MaxPerHour))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// shortDuration writes 5m rather than 5m0s.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// parseCooldown reads a cooldown like 30s, 5m or 1h30m.
+func parseCooldown(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("`%s` isn't a cooldown, try something like `30s`, `5m` or `1h`", value)
+	}
+
+	if d > maxCooldown {
+		return 0, fmt.Errorf("cooldowns can be at most %s", maxCooldown)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,82 @@
+package response
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2022, 10, 26, 10, 0, 0, 0, time.UTC)
+	always := func() int { return 0 }
+
+	l := newLimiter()
+
+	channel := &response{ChannelCooldown: time.Minute}
+	for _, step := range []struct {
+		after  time.Duration
+		userId string
+		want   bool
+	}{
+		{0, "1", true},
+		{30 * time.Second, "2", false},
+		{time.Minute, "2", true},
+	} {
+		if got := l.allow(channel, step.userId, now.Add(step.after), always); got != step.want {
+			t.Errorf("channel cooldown after %s = %t, want %t", step.after, got, step.want)
+		}
+	}
+
+	user := &response{UserCooldown: time.Minute}
+	for _, step := range []struct {
+		after  time.Duration
+		userId string
+		want   bool
+	}{
+		{0, "1", true},
+		{time.Second, "2", true},
+		{30 * time.Second, "1", false},
+		{time.Minute, "1", true},
+	} {
+		if got := l.allow(user, step.userId, now.Add(step.after), always); got != step.want {
+			t.Errorf("user cooldown for %s after %s = %t, want %t", step.userId, step.after, got, step.want)
+		}
+	}
+
+	hourly := &response{MaxPerHour: 2}
+	for _, step := range []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{time.Minute, true},
+		{2 * time.Minute, false},
+		{time.Hour, true},
+		{time.Hour + time.Minute, true},
+		{time.Hour + 2*time.Minute, false},
+	} {
+		if got := l.allow(hourly, "1", now.Add(step.after), always); got != step.want {
+			t.Errorf("max per hour after %s = %t, want %t", step.after, got, step.want)
+		}
+	}
+
+	chance := &response{Probability: 30, ChannelCooldown: time.Minute}
+	if l.allow(chance, "1", now, func() int { return 30 }) {
+		t.Error("a roll of 30 fired a 30% response")
+	}
+	if !l.allow(chance, "1", now, func() int { return 29 }) {
+		t.Error("a roll of 29 didn't fire a 30% response, or a missed roll started the cooldown")
+	}
+
+	l.forget(channel)
+	if !l.allow(channel, "1", now.Add(time.Minute+time.Second), always) {
+		t.Error("forgotten response is still cooling down")
+	}
+}
+
+func TestLimitText(t *testing.T) {
+	r := response{ChannelCooldown: 5 * time.Minute, UserCooldown: time.Hour, Probability: 50, MaxPerHour: 10}
+
+	if got, want := r.limitText(), "cooldown 5m, 1h per user, 50%, 10/hour"; got != want {
+		t.Errorf("limitText() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package response
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"rawrippers.com/grumpy-daemon/utils"
+)
+
+// Modes decide how a response's search is matched against a message. All of
+// them ignore case.
+const (
+	// ModeWord matches the search as whole words, the original behaviour.
+	ModeWord = "word"
+	// ModeSubstring matches the search anywhere, even inside a word.
+	ModeSubstring = "substring"
+	// ModeRegex treats the search as a regular expression.
+	ModeRegex = "regex"
+	// ModeGlob matches a single word against a pattern where * is any run of
+	// characters and ? is one character, like *.exe.
+	ModeGlob = "glob"
+	// ModeExact matches only a message that is the search and nothing else.
+	ModeExact = "exact"
+)
+
+const (
+	// maxSearch bounds how long a search can be.
+	maxSearch = 200
+	// maxProgram bounds the size of a compiled regex, which is what explodes
+	// with nested repetition like (a{100}){100}.
+	maxProgram = 2000
+	// maxContent is how much of a message is matched. Discord's own limit is
+	// 4000 characters, so this only trims abuse.
+	maxContent = 4000
+)
+
+// compile turns a search into the regex that MessageCreate runs, checking
+// that it is safe to run on every message.
+func compile(mode string, search string) (*regexp.Regexp, error) {
+	if len(strings.TrimSpace(search)) == 0 {
+		return nil, fmt.Errorf("the search is empty")
+	}
+
+	if len(search) > maxSearch {
+		return nil, fmt.Errorf("the search is longer than %d characters", maxSearch)
+	}
+
+	var pattern string
+
+	switch mode {
+	case ModeWord, "":
+		pattern = wordPattern(search)
+	case ModeSubstring:
+		pattern = regexp.QuoteMeta(search)
+	case ModeExact:
+		pattern = fmt.Sprintf(`^\s*%s\s*$`, regexp.QuoteMeta(strings.TrimSpace(search)))
+	case ModeGlob:
+		if strings.ContainsAny(search, " \t\n") {
+			return nil, fmt.Errorf("a glob matches a single word, so it can't contain spaces")
+		}
+		pattern = fmt.Sprintf(`(^|\s)%s($|\s)`, globPattern(search))
+	case ModeRegex:
+		pattern = search
+		if err := checkComplexity(search); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
+
+	reg, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("that isn't a valid regex: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+	}
+
+	return reg, nil
+}
+
+// notWordBefore and notWordAfter match the edges of a word, with word
+// characters as utils.IsWordRune sees them rather than the ASCII ones \b
+// knows.
+const (
+	notWordBefore = `(?:^|[^_\pL\p{Nd}\p{Mn}])`
+	notWordAfter  = `(?:$|[^_\pL\p{Nd}\p{Mn}])`
+)
+
+// wordPattern matches search as whole words like the matcher's index does,
+// only needing a boundary at edges that are word characters themselves. The
+// edges take up a character, so the search itself is the group {match}
+// shows.
+func wordPattern(search string) string {
+	pattern := "(?P<match>" + regexp.QuoteMeta(search) + ")"
+
+	if first, _ := utf8.DecodeRuneInString(search); utils.IsWordRune(first) {
+		pattern = notWordBefore + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(search); utils.IsWordRune(last) {
+		pattern += notWordAfter
+	}
+
+	return pattern
+}
+
+// globPattern translates * and ? into a regex that stays inside one word.
+func globPattern(glob string) string {
+	var pattern strings.Builder
+
+	for _, r := range glob {
+		switch r {
+		case '*':
+			pattern.WriteString(`\S*`)
+		case '?':
+			pattern.WriteString(`\S`)
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	return pattern.String()
+}
+
+// checkComplexity rejects regexes that compile to huge programs. Go's regexes
+// never backtrack, so size is what makes one expensive to run.
+func checkComplexity(search string) error {
+	re, err := syntax.Parse(search, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("that isn't a valid regex: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return fmt.Errorf("that regex is too complicated")
+	}
+
+	if len(prog.Inst) > maxProgram {
+		return fmt.Errorf("that regex is too complicated")
+	}
+
+	return nil
+}
+
+// trigger is how r is indexed. Word and substring searches are keywords, the
+// rest fall back to their compiled pattern.
+func (r *response) trigger() utils.Trigger[*response] {
+	t := utils.Trigger[*response]{Key: r.Key(), Value: r}
+
+	switch r.Mode {
+	case ModeWord, "":
+		t.Keyword = r.Search
+		t.WholeWord = true
+	case ModeSubstring:
+		t.Keyword = r.Search
+	default:
+		t.Pattern = r.pattern
+	}
+
+	return t
+}
+
+// index rebuilds the triggers for one key after its responses changed. Call
+// it with mu held.
+func index(key string) {
+	triggers := make([]utils.Trigger[*response], 0, len(responses))
+	for _, r := range responses {
+		if r.Key() == key && r.pattern != nil {
+			triggers = append(triggers, r.trigger())
+		}
+	}
+
+	matcher.Update(key, triggers)
+}
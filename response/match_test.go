@@ -0,0 +1,85 @@
+package response
+
+import (
+	"strings"
+	"testing"
+
+	"rawrippers.com/grumpy-daemon/utils"
+)
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		mode    string
+		search  string
+		content string
+		want    bool
+	}{
+		{ModeWord, "else", "something ELSE again", true},
+		{ModeWord, "thing", "something else", false},
+		{ModeWord, "else+", "something else again", false},
+		{ModeWord, "café", "un café, s'il vous plaît", true},
+		{ModeWord, "café", "cafés", false},
+		{ModeWord, "über", "Über alles", true},
+		{ModeWord, "c++", "c++, really", true},
+		{"", "else", "else", true},
+		{ModeSubstring, "thing", "something else", true},
+		{ModeExact, "ping", "  Ping ", true},
+		{ModeExact, "ping", "ping pong", false},
+		{ModeGlob, "*.exe", "run setup.exe now", true},
+		{ModeGlob, "*.exe", "exe files", false},
+		{ModeGlob, "h?llo", "hallo there", true},
+		{ModeGlob, "h?llo", "hello.world", false},
+		{ModeRegex, `deploy(ed|ing)?\b`, "we're deploying today", true},
+		{ModeRegex, `deploy(ed|ing)?\b`, "deployment", false},
+	}
+
+	for _, test := range tests {
+		pattern, err := compile(test.mode, test.search)
+		if err != nil {
+			t.Errorf("compile(%q, %q): %s", test.mode, test.search, err)
+			continue
+		}
+
+		r := &response{Search: test.search, Mode: test.mode, pattern: pattern}
+
+		m := utils.NewMatcher[*response]()
+		m.Build([]utils.Trigger[*response]{r.trigger()})
+
+		if got := len(m.Match(r.Key(), test.content)) > 0; got != test.want {
+			t.Errorf("%s %q matches %q = %t, want %t", test.mode, test.search, test.content, got, test.want)
+		}
+	}
+}
+
+func TestWordMatchShowsSearch(t *testing.T) {
+	pattern, err := compile(ModeWord, "café")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := values{match: pattern.FindStringSubmatch("un Café noir"), names: pattern.SubexpNames()}
+	if got := v.variable("match"); got != "Café" {
+		t.Errorf("{match} = %q, want %q", got, "Café")
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tests := []struct {
+		mode   string
+		search string
+	}{
+		{ModeRegex, "deploy(ed"},
+		{ModeRegex, "(a{100}){100}"},
+		{ModeRegex, "a{5000}"},
+		{ModeWord, strings.Repeat("a", maxSearch+1)},
+		{ModeWord, "  "},
+		{ModeGlob, "two words*"},
+		{"fuzzy", "thing"},
+	}
+
+	for _, test := range tests {
+		if _, err := compile(test.mode, test.search); err == nil {
+			t.Errorf("compile(%q, %q) expected an error", test.mode, test.search)
+		}
+	}
+}
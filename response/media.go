@@ -0,0 +1,184 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/utils"
+)
+
+// maxAttachmentSize is Discord's upload limit for servers without boosts.
+const maxAttachmentSize = 8 << 20
+
+// embed is what a response shows as an embed under its message.
+type embed struct {
+	Title    string `json:",omitempty"`
+	Color    int    `json:",omitempty"`
+	ImageURL string `json:",omitempty"`
+	Footer   string `json:",omitempty"`
+}
+
+// attachment is a file sent with a response. The file itself is kept in
+// the attachments directory under Hash.
+type attachment struct {
+	Name        string
+	ContentType string `json:",omitempty"`
+	Hash        string
+}
+
+// parseEmbed reads the embed options of /response, returning nil if none
+// were given.
+func parseEmbed(args command.Args) (*embed, error) {
+	e := embed{
+		Title:  args.String("title"),
+		Footer: args.String("footer"),
+	}
+
+	if args.Has("color") {
+		color, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(args.String("color")), "#"), 16, 24)
+		if err != nil {
+			return nil, fmt.Errorf("`%s` isn't a color, use hex like `#ff8800`", args.String("color"))
+		}
+		e.Color = int(color)
+	}
+
+	if args.Has("image_url") {
+		u, err := url.Parse(args.String("image_url"))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("`%s` isn't a link to an image", args.String("image_url"))
+		}
+		e.ImageURL = u.String()
+	}
+
+	if e == (embed{}) {
+		return nil, nil
+	}
+
+	return &e, nil
+}
+
+// fetchAttachment downloads the file given to /response. The attachment
+// has no Hash until keepAttachment saves the file.
+func fetchAttachment(args command.Args) (*attachment, []byte, error) {
+	if !args.Has("attachment") {
+		return nil, nil, nil
+	}
+
+	uploaded := args.Attachment("attachment")
+	if uploaded == nil {
+		return nil, nil, fmt.Errorf("I can't find that file")
+	}
+
+	if uploaded.Size > maxAttachmentSize {
+		return nil, nil, fmt.Errorf("that file is bigger than %dMB", maxAttachmentSize>>20)
+	}
+
+	file, err := utils.Download(uploaded.URL, maxAttachmentSize)
+	if err != nil {
+		log.Print(err)
+		return nil, nil, fmt.Errorf("I couldn't download that file")
+	}
+
+	return &attachment{Name: uploaded.Filename, ContentType: uploaded.ContentType}, file, nil
+}
+
+// keepAttachment saves the file of r's attachment. Call it with mu held
+// until r is in responses, or deleting another response with the same file
+// could remove it in between.
+func keepAttachment(r *response, file []byte) error {
+	if r.Attachment == nil {
+		return nil
+	}
+
+	hash, err := blobs.Put(file)
+	if err != nil {
+		log.Printf("could not save attachment %s: %s", r.Attachment.Name, err)
+		return fmt.Errorf("I couldn't save that file")
+	}
+
+	r.Attachment.Hash = hash
+	return nil
+}
+
+// media describes r's embed and attachment for /list_responses, or is empty
+// if it has neither.
+func (r *response) media() string {
+	var parts []string
+
+	if r.Embed != nil {
+		parts = append(parts, "embed")
+	}
+	if r.Attachment != nil {
+		parts = append(parts, "file "+r.Attachment.Name)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// send is what gets posted when r fires with content as its message.
+func (r *response) send(content string) *discordgo.MessageSend {
+	send := &discordgo.MessageSend{
+		Content: content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers},
+		},
+	}
+
+	var image bool
+	if r.Attachment != nil {
+		file, err := blobs.Get(r.Attachment.Hash)
+		if err != nil {
+			log.Printf("could not read attachment %s of response %q: %s", r.Attachment.Name, r.Search, err)
+		} else {
+			send.Files = []*discordgo.File{{
+				Name:        r.Attachment.Name,
+				ContentType: r.Attachment.ContentType,
+				Reader:      bytes.NewReader(file),
+			}}
+			image = strings.HasPrefix(r.Attachment.ContentType, "image/")
+		}
+	}
+
+	if r.Embed != nil {
+		e := &discordgo.MessageEmbed{
+			Title: r.Embed.Title,
+			Color: r.Embed.Color,
+		}
+		if len(r.Embed.Footer) > 0 {
+			e.Footer = &discordgo.MessageEmbedFooter{Text: r.Embed.Footer}
+		}
+		if len(r.Embed.ImageURL) > 0 {
+			e.Image = &discordgo.MessageEmbedImage{URL: r.Embed.ImageURL}
+		} else if image {
+			// Show an attached image inside the embed instead of above it.
+			e.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + r.Attachment.Name}
+		}
+		send.Embeds = []*discordgo.MessageEmbed{e}
+	}
+
+	return send
+}
+
+// forgetAttachment removes the file of a deleted response unless another
+// response uses it too. Call it with mu held, after removing r.
+func forgetAttachment(r *response) {
+	if r.Attachment == nil {
+		return
+	}
+
+	for _, other := range responses {
+		if other.Attachment != nil && other.Attachment.Hash == r.Attachment.Hash {
+			return
+		}
+	}
+
+	if err := blobs.Remove(r.Attachment.Hash); err != nil {
+		log.Printf("could not remove attachment %s: %s", r.Attachment.Name, err)
+	}
+}
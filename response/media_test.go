@@ -0,0 +1,56 @@
+package response
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+)
+
+func TestParseEmbed(t *testing.T) {
+	option := func(name string, value string) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+	}
+
+	e, err := parseEmbed(command.NewArgs(nil,
+		option("title", "Deployed"),
+		option("color", "#ff8800"),
+		option("image_url", "https://example.com/ship.gif"),
+	))
+	if err != nil || e == nil || e.Title != "Deployed" || e.Color != 0xff8800 || e.ImageURL != "https://example.com/ship.gif" {
+		t.Errorf("parseEmbed = %+v, %v", e, err)
+	}
+
+	if e, err := parseEmbed(command.NewArgs(nil)); e != nil || err != nil {
+		t.Errorf("parseEmbed without options = %+v, %v, want nothing", e, err)
+	}
+
+	for name, value := range map[string]string{"color": "orange", "image_url": "javascript:alert(1)"} {
+		if _, err := parseEmbed(command.NewArgs(nil, option(name, value))); err == nil {
+			t.Errorf("parseEmbed accepted %s %q", name, value)
+		}
+	}
+}
+
+func TestSendEmbedsAttachedImage(t *testing.T) {
+	r := response{
+		Search:     "ship it",
+		Embed:      &embed{Title: "Shipped", Footer: "grumpy"},
+		Attachment: &attachment{Name: "ship.gif", ContentType: "image/gif", Hash: "not stored"},
+	}
+
+	send := r.send("hello")
+
+	if send.Content != "hello" || len(send.Embeds) != 1 || send.Embeds[0].Footer.Text != "grumpy" {
+		t.Errorf("send = %+v", send)
+	}
+
+	// The file can't be read, so it isn't shown in the embed either.
+	if len(send.Files) != 0 || send.Embeds[0].Image != nil {
+		t.Errorf("send used a missing file: %+v", send)
+	}
+
+	if got, want := r.media(), "embed, file ship.gif"; got != want {
+		t.Errorf("media() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package response
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+)
+
+var wholeText = regexp.MustCompile(`(?s).+`)
+
+// PreviewResponse renders a response message against some sample text,
+// without saving anything.
+func PreviewResponse(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         preview(i, args),
+			Flags:           discordgo.MessageFlagsEphemeral,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+}
+
+func preview(i *discordgo.InteractionCreate, args command.Args) string {
+	message := args.String("message")
+	text := args.String("text")
+	mode := ModeWord
+
+	if args.Has("mode") {
+		mode = args.String("mode")
+	}
+
+	// Without a search, the whole text is the match.
+	search := "the whole text"
+	pattern := wholeText
+
+	if args.Has("search") {
+		search = args.String("search")
+
+		var err error
+		if pattern, err = compile(mode, search); err != nil {
+			return fmt.Sprintf("I can't use `%s` as a %s search: %s.", search, mode, err)
+		}
+	}
+
+	tmpl, err := parseTemplate(message, pattern)
+	if err != nil {
+		return fmt.Sprintf("I can't use `%s` as a message: %s.", message, err)
+	}
+
+	match := pattern.FindStringSubmatch(text)
+	if match == nil {
+		return fmt.Sprintf("`%s` doesn't match that text, so nothing would be sent.", search)
+	}
+
+	user := args.User.Username
+	if i.Member != nil && len(i.Member.Nick) > 0 {
+		user = i.Member.Nick
+	}
+
+	return tmpl.render(values{
+		user:      user,
+		userId:    args.User.ID,
+		channelId: i.ChannelID,
+		match:     match,
+		names:     pattern.SubexpNames(),
+		now:       time.Now(),
+	})
+}
@@ -1,50 +1,115 @@
 package response
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/permission"
+	"rawrippers.com/grumpy-daemon/store"
 	"rawrippers.com/grumpy-daemon/utils"
 )
 
 type response struct {
-	Message   string
-	Search    string
-	ChannelId string
+	Message string
+	Search  string
+	utils.Scope
+	Mode string `json:",omitempty"`
+	// ChannelCooldown and UserCooldown are how long the response stays
+	// quiet after firing, in the channel or for the user who triggered it.
+	ChannelCooldown time.Duration `json:",omitempty"`
+	UserCooldown    time.Duration `json:",omitempty"`
+	// Probability is the percent chance it fires when it matches. 0 means
+	// it always does.
+	Probability int         `json:",omitempty"`
+	MaxPerHour  int         `json:",omitempty"`
+	Embed       *embed      `json:",omitempty"`
+	Attachment  *attachment `json:",omitempty"`
+	// AuthorId is who set the response. Responses set before it was
+	// recorded have none.
+	AuthorId string `json:",omitempty"`
+
+	pattern  *regexp.Regexp
+	template template
 }
 
 var (
 	mu        sync.Mutex
 	responses []*response
+	repo      store.Repository[*response]
+	matcher   = utils.NewMatcher[*response]()
+	blobs     store.Blobs
 )
 
-func Load() {
-	read()
+// Load reads the saved responses. It fails rather than start with none, which
+// the next save would write over the ones that couldn't be read.
+func Load(st *store.Store) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loading := store.Collection[*response](st, "responses")
+	blobs = st.Blobs("attachments")
+
+	loaded, err := loading.Load()
+	if err != nil {
+		return fmt.Errorf("could not load responses: %w", err)
+	}
+	repo = loading
+
+	responses = loaded
+	log.Printf("loaded %d responses", len(responses))
+
+	var triggers []utils.Trigger[*response]
+	for _, r := range responses {
+		if r.pattern, err = compile(r.Mode, r.Search); err != nil {
+			log.Printf("response %q in %s won't fire: %s", r.Search, r.ChannelId, err)
+			continue
+		}
+		if r.template, err = parseTemplate(r.Message, r.pattern); err != nil {
+			// Saved before messages were templates, so send it as it is.
+			r.template = template{{text: r.Message}}
+		}
+		triggers = append(triggers, r.trigger())
+	}
+	matcher.Build(triggers)
+
+	return nil
 }
 
-func SetResponse(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func SetResponse(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	// Downloading an attachment can take longer than an interaction may go
+	// unanswered.
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: set(s, i),
-		},
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	content := set(s, i, args)
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
 	})
 }
 
-func ListResponses(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// ListPages is the custom id prefix of the /list_responses page buttons.
+const ListPages = "list_responses"
+
+func ListResponses(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: list(i),
-		},
+		Data: list(s, i).Page(0),
 	})
 }
 
+// ListResponsesPage shows another page of /list_responses.
+func ListResponsesPage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	list(s, i).Turn(s, i, utils.PageOf(i))
+}
+
 func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if m.Author.ID == s.State.User.ID {
 		return
@@ -54,185 +119,295 @@ func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 
+	content := m.Content
+	if len(content) > maxContent {
+		content = strings.ToValidUTF8(content[:maxContent], "")
+	}
+
+	user := m.Author.Username
+	if m.Member != nil && len(m.Member.Nick) > 0 {
+		user = m.Member.Nick
+	}
+
+	var matched []*response
+	for _, key := range utils.Locate(s, m.GuildID, m.ChannelID).Keys() {
+		matched = append(matched, matcher.Match(key, content)...)
+	}
+
+	if len(matched) == 0 {
+		return
+	}
+
 	mu.Lock()
-	for _, r := range responses {
-		if r.ChannelId == m.ChannelID && utils.ContainsSearch(strings.ToLower(m.Content), strings.ToLower(r.Search)) {
-			s.ChannelMessageSendReply(m.ChannelID, r.Message, m.Reference())
+	var firing []*response
+	for _, r := range matched {
+		if !r.OptedOut(m.ChannelID) {
+			firing = append(firing, r)
 		}
 	}
 	mu.Unlock()
+
+	for _, r := range firing {
+		if !limits.allow(r, m.Author.ID, time.Now(), roll) {
+			continue
+		}
+
+		send := r.send(r.template.render(values{
+			user:      user,
+			userId:    m.Author.ID,
+			channelId: m.ChannelID,
+			match:     r.pattern.FindStringSubmatch(content),
+			names:     r.pattern.SubexpNames(),
+			now:       time.Now(),
+		}))
+		send.Reference = m.Reference()
+
+		s.ChannelMessageSendComplex(m.ChannelID, send)
+	}
 }
 
-func DeleteResponse(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func DeleteResponse(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: delete(s, i),
+			Content: remove(s, i, args),
 		},
 	})
 }
 
-func delete(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
-	}
-
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
-	}
+// DeleteResponseAutocomplete suggests the searches of the responses that apply
+// in the channel for /delete_response.
+func DeleteResponseAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	utils.Suggest(s, i, args.String(args.Focused), choices(utils.Locate(s, i.GuildID, i.ChannelID)))
+}
 
-	options := i.ApplicationCommandData().Options
+// choices lists each search once, even when it is set at more than one
+// scope, since deleting it deletes them all.
+func choices(where utils.Where) []utils.Choice {
+	mu.Lock()
+	defer mu.Unlock()
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
+	var choices []utils.Choice
+	seen := make(map[string]bool)
+	for _, r := range responses {
+		if !r.AppliesIn(where) || seen[r.Search] {
+			continue
+		}
+		seen[r.Search] = true
+		choices = append(choices, utils.Choice{Name: r.Search, Value: r.Search})
 	}
 
-	var search string
+	return choices
+}
 
-	if option, ok := optionMap["search"]; ok {
-		search = option.StringValue()
-	} else {
-		return "search is required."
-	}
+func remove(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	search := args.String("search")
+
+	where := utils.Locate(s, i.GuildID, i.ChannelID)
 
-	deleted := false
+	var deleted []*response
 	mu.Lock()
-	for index, r := range responses {
-		if r.ChannelId == i.ChannelID && r.Search == search {
-			responses[index] = responses[len(responses)-1]
-			responses = responses[:len(responses)-1]
-			deleted = true
+	for _, r := range responses {
+		if r.AppliesIn(where) && r.Search == search && !permission.MayDelete(i, r.AuthorId, "deleting response "+search) {
+			mu.Unlock()
+			return fmt.Sprintf("Response `%s` isn't yours, you need `%s` to delete it.", search, permission.DeleteAny)
+		}
+	}
+	kept := responses[:0]
+	for _, r := range responses {
+		if r.AppliesIn(where) && r.Search == search {
+			deleted = append(deleted, r)
+			continue
 		}
+		kept = append(kept, r)
+	}
+	responses = kept
+	for _, r := range deleted {
+		limits.forget(r)
+		index(r.Key())
+		forgetAttachment(r)
+	}
+	if len(deleted) > 0 {
+		write()
 	}
 	mu.Unlock()
 
-	if deleted {
-		return fmt.Sprintf("<@%s> deleted response `%s`.", i.Member.User.ID, search)
+	if len(deleted) > 0 {
+		return fmt.Sprintf("<@%s> deleted response `%s`.", args.User.ID, search)
 	} else {
 		return fmt.Sprintf("Could not find response `%s` to delete.", search)
 	}
 }
 
-func list(i *discordgo.InteractionCreate) string {
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
-	}
+func OptOutResponse(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: optOut(s, i, args),
+		},
+	})
+}
 
-	responsesResp := ""
+// optOut switches a category or guild response off in this channel, or back
+// on if it already was.
+func optOut(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	search := args.String("search")
 
-	mu.Lock()
+	where := utils.Locate(s, i.GuildID, i.ChannelID)
 
+	var off, on int
+	mu.Lock()
 	for _, r := range responses {
-		if r.ChannelId == i.ChannelID {
-			responsesResp = fmt.Sprintf("%s\n%s:\t\t%s", responsesResp, r.Search, r.Message)
+		if r.Search != search || !r.AppliesIn(where) || r.Name() == utils.ScopeChannel {
+			continue
+		}
+		if r.ToggleOptOut(i.ChannelID) {
+			off++
+		} else {
+			on++
 		}
 	}
-
+	if off+on > 0 {
+		write()
+	}
 	mu.Unlock()
 
-	if len(responsesResp) == 0 {
-		return "no responses"
+	switch {
+	case off > 0:
+		return fmt.Sprintf("<@%s> turned response `%s` off in this channel.", args.User.ID, search)
+	case on > 0:
+		return fmt.Sprintf("<@%s> turned response `%s` back on in this channel.", args.User.ID, search)
 	}
 
-	return fmt.Sprintf("```%s```", responsesResp)
+	return fmt.Sprintf("Could not find a category or server response `%s` here. Use /delete_response for channel responses.", search)
 }
 
-func set(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
-	}
+func list(s *discordgo.Session, i *discordgo.InteractionCreate) utils.List {
+	l := utils.List{Prefix: ListPages, Title: "Responses", Empty: "no responses", Code: true}
 
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
-	}
+	where := utils.Locate(s, i.GuildID, i.ChannelID)
 
-	options := i.ApplicationCommandData().Options
+	mu.Lock()
 
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
+	for _, r := range responses {
+		if r.AppliesIn(where) {
+			line := fmt.Sprintf("%s:\t%s\t%s\t%s", r.Search, r.Name(), r.mode(), r.Message)
+			if r.OptedOut(i.ChannelID) {
+				line += "\t(off here)"
+			}
+			if limits := r.limitText(); len(limits) > 0 {
+				line = fmt.Sprintf("%s\t(%s)", line, limits)
+			}
+			if media := r.media(); len(media) > 0 {
+				line = fmt.Sprintf("%s\t[%s]", line, media)
+			}
+			l.Lines = append(l.Lines, line)
+		}
 	}
 
-	var message string
-	var search string
+	mu.Unlock()
 
-	if option, ok := optionMap["message"]; ok {
-		message = option.StringValue()
-	} else {
-		return "Message is required."
-	}
+	return l
+}
 
-	if option, ok := optionMap["search"]; ok {
-		search = option.StringValue()
-	} else {
-		return "Search is required."
-	}
+func set(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	message := args.String("message")
+	search := args.String("search")
+	mode := ModeWord
+	level := utils.ScopeChannel
 
-	r := response{
-		Message:   message,
-		Search:    search,
-		ChannelId: i.ChannelID,
+	if args.Has("mode") {
+		mode = args.String("mode")
 	}
 
-	mu.Lock()
-	responses = append(responses, &r)
-	write()
-	mu.Unlock()
+	if args.Has("scope") {
+		level = args.String("scope")
+	}
 
-	return fmt.Sprintf("<@%s> set a response `%s` to `%s`. Use /list_responses to see responses.", i.Member.User.ID, message, search)
-}
+	pattern, err := compile(mode, search)
+	if err != nil {
+		return fmt.Sprintf("I can't use `%s` as a %s search: %s.", search, mode, err)
+	}
 
-func write() {
-	createDirs()
-	homedir := homeDir()
-	file, err := json.MarshalIndent(&responses, "", " ")
+	tmpl, err := parseTemplate(message, pattern)
+	if err != nil {
+		return fmt.Sprintf("I can't use `%s` as a message: %s.", message, err)
+	}
 
+	scope, err := utils.NewScope(s, level, i.GuildID, i.ChannelID)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Sprintf("I can't set a %s response here: %s.", level, err)
 	}
 
-	err = os.WriteFile(fmt.Sprintf("%s/.grumpy/responses/responses.json", homedir), file, 0644)
+	r := response{
+		Message:  message,
+		Search:   search,
+		Scope:    scope,
+		Mode:     mode,
+		AuthorId: args.User.ID,
+		pattern:  pattern,
+		template: tmpl,
+	}
 
-	if err != nil {
-		log.Fatal(err)
+	if args.Has("cooldown") {
+		if r.ChannelCooldown, err = parseCooldown(args.String("cooldown")); err != nil {
+			return fmt.Sprintf("I can't use that cooldown: %s.", err)
+		}
 	}
-}
 
-func read() {
-	createDirs()
-	homedir := homeDir()
-	file, err := os.ReadFile(fmt.Sprintf("%s/.grumpy/responses/responses.json", homedir))
+	if args.Has("user_cooldown") {
+		if r.UserCooldown, err = parseCooldown(args.String("user_cooldown")); err != nil {
+			return fmt.Sprintf("I can't use that user cooldown: %s.", err)
+		}
+	}
 
-	if err != nil {
-		log.Printf("Could not open responses: %s", err)
-		return
+	if args.Has("probability") {
+		r.Probability = int(args.Int("probability"))
+		if r.Probability < 1 || r.Probability > 100 {
+			return "Probability is a percentage from 1 to 100."
+		}
 	}
 
-	err = json.Unmarshal(file, &responses)
+	if args.Has("max_per_hour") {
+		r.MaxPerHour = int(args.Int("max_per_hour"))
+		if r.MaxPerHour < 1 {
+			return "Max per hour has to be at least 1."
+		}
+	}
 
-	log.Printf("loaded %d responses", len(responses))
+	if r.Embed, err = parseEmbed(args); err != nil {
+		return fmt.Sprintf("I can't use that embed: %s.", err)
+	}
 
-	if err != nil {
-		log.Fatal(err)
+	var file []byte
+	if r.Attachment, file, err = fetchAttachment(args); err != nil {
+		return fmt.Sprintf("I can't use that attachment: %s.", err)
 	}
-}
 
-func homeDir() string {
-	homedir, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatal(err)
+	mu.Lock()
+	if err := keepAttachment(&r, file); err != nil {
+		mu.Unlock()
+		return fmt.Sprintf("I can't use that attachment: %s.", err)
 	}
-	return homedir
-}
+	responses = append(responses, &r)
+	index(r.Key())
+	write()
+	mu.Unlock()
 
-func createDirs() {
-	homedir := homeDir()
+	return fmt.Sprintf("<@%s> set a %s response `%s` to `%s` (%s). Use /list_responses to see responses.", args.User.ID, r.Name(), message, search, mode)
+}
 
-	path := fmt.Sprintf("%s/.grumpy/responses/", homedir)
-	err := os.MkdirAll(path, os.ModePerm)
+// mode is r's mode, counting responses saved before there were modes as word
+// matches.
+func (r *response) mode() string {
+	if len(r.Mode) == 0 {
+		return ModeWord
+	}
+	return r.Mode
+}
 
-	if err != nil {
-		log.Fatal(err)
+func write() {
+	if err := repo.Save(responses); err != nil {
+		log.Printf("could not save responses: %s", err)
 	}
 }
@@ -0,0 +1,213 @@
+package response
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A response message is a template. Anything in braces is replaced when the
+// response fires:
+//
+//	{user}      the name of whoever triggered it
+//	{mention}   a mention of whoever triggered it
+//	{channel}   a link to the channel
+//	{match}     the text that matched the search
+//	{date}      today's date, shown in each reader's timezone
+//	{1}, {2}    capture groups of a regex search, {name} for named ones
+//	{a|b|c}     one of a, b or c, picked at random
+//
+// {{ and }} stand for literal braces. Nothing else is evaluated, so a template
+// can't do more than fill in these values.
+
+// maxOutput keeps a rendered response inside Discord's message limit.
+const maxOutput = 2000
+
+var variables = map[string]bool{
+	"user":    true,
+	"mention": true,
+	"channel": true,
+	"match":   true,
+	"date":    true,
+}
+
+type template []segment
+
+// segment is literal text, a variable, a capture group or a random choice.
+type segment struct {
+	text     string
+	variable string
+	group    string
+	choices  []string
+}
+
+// values is what a template is rendered with.
+type values struct {
+	user      string
+	userId    string
+	channelId string
+	match     []string
+	names     []string
+	now       time.Time
+}
+
+// parseTemplate checks message and splits it into segments. pattern is the
+// search the template responds to, for checking capture groups.
+func parseTemplate(message string, pattern *regexp.Regexp) (template, error) {
+	var t template
+	var text strings.Builder
+
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+
+		if c == '}' {
+			if i+1 < len(message) && message[i+1] == '}' {
+				text.WriteByte('}')
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("there's a `}` without a `{`, write `}}` for a literal one")
+		}
+
+		if c != '{' {
+			text.WriteByte(c)
+			continue
+		}
+
+		if i+1 < len(message) && message[i+1] == '{' {
+			text.WriteByte('{')
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(message[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("there's a `{` without a `}`, write `{{` for a literal one")
+		}
+
+		inside := message[i+1 : i+end]
+		i += end
+
+		s, err := parseSegment(inside, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		if text.Len() > 0 {
+			t = append(t, segment{text: text.String()})
+			text.Reset()
+		}
+		t = append(t, s)
+	}
+
+	if text.Len() > 0 {
+		t = append(t, segment{text: text.String()})
+	}
+
+	return t, nil
+}
+
+func parseSegment(inside string, pattern *regexp.Regexp) (segment, error) {
+	if strings.Contains(inside, "|") {
+		return segment{choices: strings.Split(inside, "|")}, nil
+	}
+
+	name := strings.TrimSpace(inside)
+
+	if variables[name] {
+		return segment{variable: name}, nil
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		if pattern == nil || n < 1 || n > pattern.NumSubexp() {
+			return segment{}, fmt.Errorf("the search has no capture group {%s}", name)
+		}
+		return segment{group: name}, nil
+	}
+
+	if pattern != nil && pattern.SubexpIndex(name) > 0 {
+		return segment{group: name}, nil
+	}
+
+	return segment{}, fmt.Errorf("I don't know {%s}, use {user}, {mention}, {channel}, {match}, {date}, a capture group or {a|b}", name)
+}
+
+// render fills in t. The result is cut to maxOutput and can't ping
+// @everyone or @here.
+func (t template) render(v values) string {
+	var out strings.Builder
+
+	for _, s := range t {
+		switch {
+		case len(s.choices) > 0:
+			out.WriteString(s.choices[rand.Intn(len(s.choices))])
+		case len(s.variable) > 0:
+			out.WriteString(v.variable(s.variable))
+		case len(s.group) > 0:
+			out.WriteString(v.group(s.group))
+		default:
+			out.WriteString(s.text)
+		}
+
+		if out.Len() > maxOutput {
+			break
+		}
+	}
+
+	rendered := escapeMentions(out.String())
+	if len(rendered) > maxOutput {
+		rendered = strings.ToValidUTF8(rendered[:maxOutput-len("…")], "") + "…"
+	}
+
+	return rendered
+}
+
+func (v values) variable(name string) string {
+	switch name {
+	case "user":
+		return v.user
+	case "mention":
+		return fmt.Sprintf("<@%s>", v.userId)
+	case "channel":
+		return fmt.Sprintf("<#%s>", v.channelId)
+	case "match":
+		if len(v.match) > 0 {
+			if group := v.group("match"); len(group) > 0 {
+				return group
+			}
+			return v.match[0]
+		}
+	case "date":
+		return fmt.Sprintf("<t:%d:D>", v.now.Unix())
+	}
+	return ""
+}
+
+func (v values) group(name string) string {
+	index, err := strconv.Atoi(name)
+	if err != nil {
+		index = -1
+		for i, n := range v.names {
+			if n == name {
+				index = i
+				break
+			}
+		}
+	}
+
+	if index < 1 || index >= len(v.match) {
+		return ""
+	}
+
+	return v.match[index]
+}
+
+// escapeMentions breaks up @everyone and @here with a zero width space so
+// they show as text instead of pinging.
+func escapeMentions(s string) string {
+	s = strings.ReplaceAll(s, "@everyone", "@\u200beveryone")
+	return strings.ReplaceAll(s, "@here", "@\u200bhere")
+}
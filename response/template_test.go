@@ -0,0 +1,80 @@
+package response
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	pattern := regexp.MustCompile(`(?i)deploy(?P<tense>ed|ing) (\w+)`)
+	content := "we're deploying grumpy today"
+
+	v := values{
+		user:      "sam",
+		userId:    "42",
+		channelId: "7",
+		match:     pattern.FindStringSubmatch(content),
+		names:     pattern.SubexpNames(),
+		now:       time.Unix(1666778400, 0),
+	}
+
+	tests := map[string]string{
+		"hi {user}":                     "hi sam",
+		"{mention} in {channel}":        "<@42> in <#7>",
+		"you said {match}":              "you said deploying grumpy",
+		"{tense} {2} on {date}":         "ing grumpy on <t:1666778400:D>",
+		"{{literal}} }}":                "{literal} }",
+		"{same|same}":                   "same",
+		"@everyone look, @here too":     "@\u200beveryone look, @\u200bhere too",
+		"no variables at all":           "no variables at all",
+		"{ user }":                      "sam",
+		strings.Repeat("x", 3000):       strings.Repeat("x", maxOutput-len("…")) + "…",
+		"{1}" + strings.Repeat("y", 10): "ing" + strings.Repeat("y", 10),
+	}
+
+	for message, want := range tests {
+		tmpl, err := parseTemplate(message, pattern)
+		if err != nil {
+			t.Errorf("parseTemplate(%q): %s", message, err)
+			continue
+		}
+
+		if got := tmpl.render(v); got != want {
+			t.Errorf("render(%q) = %q, want %q", message, got, want)
+		}
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	pattern := regexp.MustCompile(`(?i)\b(hello)\b`)
+
+	for _, message := range []string{
+		"unclosed {user",
+		"stray } brace",
+		"{unknown}",
+		"{2}",
+		"{printf \"%s\" .}",
+	} {
+		if _, err := parseTemplate(message, pattern); err == nil {
+			t.Errorf("parseTemplate(%q) expected an error", message)
+		}
+	}
+}
+
+func TestRandomChoice(t *testing.T) {
+	tmpl, err := parseTemplate("{a|b|c}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		seen[tmpl.render(values{})] = true
+	}
+
+	if len(seen) != 3 || !seen["a"] || !seen["b"] || !seen["c"] {
+		t.Errorf("random choices = %v, want a, b and c", seen)
+	}
+}
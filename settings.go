@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/config"
+	"rawrippers.com/grumpy-daemon/reminder"
+	"rawrippers.com/grumpy-daemon/stable"
+)
+
+var (
+	confMu sync.Mutex
+	// conf is the configuration in effect. Sections that need a restart
+	// keep what the bot started with.
+	conf *config.Config
+)
+
+func current() *config.Config {
+	confMu.Lock()
+	defer confMu.Unlock()
+
+	return conf
+}
+
+// loadConfig reads the config file -config or GRUMPY_CONFIG names, if any,
+// with the environment and flags over it.
+func loadConfig() (*config.Config, error) {
+	path := *ConfigFile
+	if len(path) == 0 {
+		path = os.Getenv("GRUMPY_CONFIG")
+	}
+
+	c, err := config.Load(path, os.Getenv, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := check(c); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return c, nil
+}
+
+// check checks the settings the config package leaves to the features they
+// belong to.
+func check(c *config.Config) error {
+	var problems []string
+
+	if err := catchUp(c).Check(); err != nil {
+		problems = append(problems, fmt.Sprintf("reminders: %s", err))
+	}
+
+	for n, name := range c.Features.Disabled {
+		switch {
+		case name == "grumpy":
+			problems = append(problems, fmt.Sprintf("features.disabled[%d]: /grumpy can't be turned off, it reloads the configuration", n))
+		case !command.Known(name):
+			problems = append(problems, fmt.Sprintf("features.disabled[%d]: there is no command %q", n, name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func catchUp(c *config.Config) reminder.CatchUp {
+	return reminder.CatchUp{
+		Mode:   c.Reminders.CatchUp,
+		MaxAge: c.Reminders.CatchUpMaxAge,
+		Replay: c.Reminders.CatchUpReplay,
+	}
+}
+
+// flags applies the flags given on the command line.
+func flags(c *config.Config) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "guild":
+			c.Discord.Guilds = config.SplitList(*GuildID)
+		case "token":
+			c.Discord.Token = *BotToken
+			c.Discord.TokenFile = ""
+		case "rmcmd":
+			c.Discord.RemoveCommands = *RemoveCommands
+		case "shutdown-timeout":
+			c.Shutdown.Timeout = *ShutdownTimeout
+		case "catchup":
+			c.Reminders.CatchUp = *CatchUp
+		case "catchup-max-age":
+			c.Reminders.CatchUpMaxAge = *CatchUpMaxAge
+		case "catchup-replay":
+			c.Reminders.CatchUpReplay = *CatchUpReplay
+		case "data-dir":
+			c.Store.DataDir = *DataDir
+		case "store":
+			c.Store.Backend = *StoreBackend
+		}
+	})
+}
+
+// apply puts the sections that can change while the bot runs into effect.
+// Shutdown and Discord.RemoveCommands are read when shutting down.
+func apply(c *config.Config) {
+	if err := reminder.SetCatchUp(catchUp(c)); err != nil {
+		// check already did.
+		log.Printf("Invalid catch up policy: %v", err)
+	}
+
+	stable.Configure(stable.Settings{
+		URL:          c.Stable.URL,
+		SubmitURL:    c.Stable.SubmitURL,
+		StatusURL:    c.Stable.StatusURL,
+		MaxTries:     c.Stable.MaxTries,
+		PollInterval: c.Stable.PollInterval,
+		MaxSize:      c.Stable.MaxSize,
+	})
+
+	command.Disable(c.Features.Disabled)
+}
+
+// reload reads the configuration again and applies the sections that can
+// change while the bot runs, syncing the commands if different ones are
+// turned off. A configuration that doesn't load changes nothing. It says
+// which changed settings need a restart.
+func reload() (string, error) {
+	confMu.Lock()
+	defer confMu.Unlock()
+
+	c, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+
+	var restart []string
+	if c.Discord.Token != conf.Discord.Token {
+		restart = append(restart, "discord.token")
+	}
+	if !reflect.DeepEqual(c.Discord.Guilds, conf.Discord.Guilds) {
+		restart = append(restart, "discord.guilds")
+	}
+	if c.Store != conf.Store {
+		restart = append(restart, "store")
+	}
+	c.Discord.Token = conf.Discord.Token
+	c.Discord.TokenFile = conf.Discord.TokenFile
+	c.Discord.Guilds = conf.Discord.Guilds
+	c.Store = conf.Store
+
+	apply(c)
+	resync := !reflect.DeepEqual(c.Features.Disabled, conf.Features.Disabled)
+	conf = c
+
+	message := "Reloaded the configuration."
+	if resync {
+		if err := syncCommands(s.State.User.ID, c.Discord.Guilds, false); err != nil {
+			message += fmt.Sprintf(" Syncing the commands failed, Discord may offer the wrong ones: %v.", err)
+		}
+	}
+	if len(restart) > 0 {
+		message += fmt.Sprintf(" Restart to apply the new %s.", strings.Join(restart, ", "))
+	}
+	return message, nil
+}
@@ -0,0 +1,59 @@
+package stable
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+)
+
+func init() {
+	command.Register(&command.Command{
+		Name:        "stable",
+		Description: "Stable diffusion",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "prompt",
+				Description: "Stable diffusion prompt",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "width",
+				Description: "width",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "height",
+				Description: "height",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "num_outputs",
+				Description: "number of images",
+				Required:    false,
+				MaxValue:    4,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        "guidance_scale",
+				Description: "guidance scale",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        "prompt_strength",
+				Description: "prompt strength",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "num_inference_steps",
+				Description: "number of inference steps",
+				Required:    false,
+			},
+		},
+		Handler: Stable,
+	})
+}
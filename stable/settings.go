@@ -0,0 +1,44 @@
+package stable
+
+import (
+	"sync"
+	"time"
+)
+
+// Settings are where the Stable Diffusion server is and how patient to be
+// with it.
+type Settings struct {
+	URL       string
+	SubmitURL string
+	StatusURL string
+	// MaxTries is how many times to ask whether an image is done, waiting
+	// PollInterval in between.
+	MaxTries     int
+	PollInterval time.Duration
+	// MaxSize is the widest and tallest image anyone may ask for.
+	MaxSize int64
+}
+
+var (
+	settingsMu sync.Mutex
+	settings   = Settings{
+		MaxTries:     50,
+		PollInterval: 3 * time.Second,
+		MaxSize:      1024,
+	}
+)
+
+// Configure changes the settings. Images already being built keep the ones
+// they started with.
+func Configure(s Settings) {
+	settingsMu.Lock()
+	settings = s
+	settingsMu.Unlock()
+}
+
+func current() Settings {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	return settings
+}
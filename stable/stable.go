@@ -2,16 +2,17 @@ package stable
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
 )
 
 type PredictionsResp struct {
@@ -42,113 +43,88 @@ type Input struct {
 	Prompt            string  `json:"prompt"`
 }
 
-func Stable(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func Stable(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: StableGet(s, i),
+			Content: StableGet(s, i, args),
 		},
 	})
 }
 
-func StableGet(s *discordgo.Session, i *discordgo.InteractionCreate) string {
-	if i.Member == nil || i.Member.User == nil {
-		return "Who are you?"
-	}
+func StableGet(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) string {
+	input := createInputFromArgs(args)
+	settings := current()
 
-	if len(i.ChannelID) == 0 {
-		return "Where is this coming from?"
+	if input.Width > settings.MaxSize || input.Height > settings.MaxSize {
+		return fmt.Sprintf("Images can be at most %dx%d.", settings.MaxSize, settings.MaxSize)
 	}
 
-	username := fmt.Sprintf("<@%s>", i.Member.User.ID)
-	channelId := i.ChannelID
-
-	options := i.ApplicationCommandData().Options
-
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
-	}
-
-	input, err := createInputFromArgs(optionMap)
-
-	if err != nil {
-		return err.Error()
-	}
-
-	go runStable(s, username, channelId, input, i.Interaction)
+	command.Go(s, i, func(ctx context.Context) {
+		runStable(ctx, s, i, settings, input)
+	})
 	return fmt.Sprintf("Buildin' an image for \"%s\"", input.Prompt)
 }
 
-func createInputFromArgs(optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*Input, error) {
-	input := Input{}
-
-	if option, ok := optionMap["prompt"]; ok {
-		input.Prompt = option.StringValue()
-	} else {
-		return nil, fmt.Errorf("no prompt")
+func createInputFromArgs(args command.Args) *Input {
+	input := Input{
+		Prompt:            args.String("prompt"),
+		Width:             512,
+		Height:            512,
+		NumOutputs:        "1",
+		GuidanceScale:     7.5,
+		PromptStrength:    0.8,
+		NumInferenceSteps: 50,
 	}
 
-	if option, ok := optionMap["width"]; ok {
-		input.Width = option.IntValue()
-	} else {
-		input.Width = 512
+	if args.Has("width") {
+		input.Width = args.Int("width")
 	}
 
-	if option, ok := optionMap["height"]; ok {
-		input.Height = option.IntValue()
-	} else {
-		input.Height = 512
+	if args.Has("height") {
+		input.Height = args.Int("height")
 	}
 
-	if option, ok := optionMap["num_outputs"]; ok {
-		input.NumOutputs = fmt.Sprint(option.IntValue())
-	} else {
-		input.NumOutputs = "1"
+	if args.Has("num_outputs") {
+		input.NumOutputs = fmt.Sprint(args.Int("num_outputs"))
 	}
 
-	if option, ok := optionMap["guidance_scale"]; ok {
-		input.GuidanceScale = option.FloatValue()
-	} else {
-		input.GuidanceScale = 7.5
+	if args.Has("guidance_scale") {
+		input.GuidanceScale = args.Float("guidance_scale")
 	}
 
-	if option, ok := optionMap["prompt_strength"]; ok {
-		input.PromptStrength = option.FloatValue()
-	} else {
-		input.PromptStrength = 0.8
+	if args.Has("prompt_strength") {
+		input.PromptStrength = args.Float("prompt_strength")
 	}
 
-	if option, ok := optionMap["num_inference_steps"]; ok {
-		input.NumInferenceSteps = option.IntValue()
-	} else {
-		input.NumInferenceSteps = 50
+	if args.Has("num_inference_steps") {
+		input.NumInferenceSteps = args.Int("num_inference_steps")
 	}
 
-	return &input, nil
+	return &input
 }
 
-func runStable(s *discordgo.Session, username string, channelId string, input *Input, interaction *discordgo.Interaction) {
-	image, err := callStableApi(input)
+func runStable(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, settings Settings, input *Input) {
+	image, err := callStableApi(ctx, settings, input)
 
 	if err != nil {
-		s.FollowupMessageCreate(interaction, false, &discordgo.WebhookParams{
+		s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
 			Content: err.Error(),
 		})
 	} else {
-		s.FollowupMessageCreate(interaction, false, &discordgo.WebhookParams{
+		s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
 			Content: image,
 		})
 	}
 }
 
-func callStableApi(input *Input) (string, error) {
-	stableUrl := os.Getenv("STABLE_URL")
+func callStableApi(ctx context.Context, settings Settings, input *Input) (string, error) {
+	stableUrl := settings.URL
 	if len(stableUrl) == 0 {
-		return "", fmt.Errorf("stable_url not set")
+		return "", fmt.Errorf("stable.url not set")
 	}
 	// first get the CSRF
-	resp, err := http.Get(stableUrl)
+	resp, err := get(ctx, stableUrl)
 	if err != nil {
 		return "", fmt.Errorf("failed to connect")
 	}
@@ -175,25 +151,31 @@ func callStableApi(input *Input) (string, error) {
 	}
 	payloadBuf.Write(requestData)
 
-	stableSubmitUrl := os.Getenv("STABLE_SUBMIT_URL")
+	stableSubmitUrl := settings.SubmitURL
 	if len(stableSubmitUrl) == 0 {
-		return "", fmt.Errorf("stable_submit_url not set")
+		return "", fmt.Errorf("stable.submit_url not set")
+	}
+	submit, err := http.NewRequestWithContext(ctx, http.MethodPost, stableSubmitUrl, &payloadBuf)
+	if err != nil {
+		return "", fmt.Errorf("failed to construct request")
 	}
-	resp, err = http.Post(stableSubmitUrl, "application/json", &payloadBuf)
+	submit.Header.Set("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(submit)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("stable submit failed: %s", err)
+		return "", fmt.Errorf("failed to submit")
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("Response code: %s", resp.Status)
 		log.Printf("Body: '%s'", body)
-		log.Fatal(err)
+		return "", fmt.Errorf("failed to read submit response")
 	}
 	var predictionsResp PredictionsResp
 	err = json.Unmarshal(body, &predictionsResp)
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("error unmarshalling submit response")
 	}
 	uuid := predictionsResp.Uuid
 
@@ -202,13 +184,13 @@ func callStableApi(input *Input) (string, error) {
 	success := false
 	var url string
 
-	triesMax := 50
+	triesMax := settings.MaxTries
 	for !success && tries < triesMax {
-		stableStatusUrl := os.Getenv("STABLE_STATUS_URL")
+		stableStatusUrl := settings.StatusURL
 		if len(stableStatusUrl) == 0 {
-			return "", fmt.Errorf("stable_status_url not set")
+			return "", fmt.Errorf("stable.status_url not set")
 		}
-		resp, err = http.Get(fmt.Sprintf("%s/%s", stableStatusUrl, uuid))
+		resp, err = get(ctx, fmt.Sprintf("%s/%s", stableStatusUrl, uuid))
 		if err != nil {
 			return "", fmt.Errorf("error connecting to status url")
 		}
@@ -229,7 +211,11 @@ func callStableApi(input *Input) (string, error) {
 		} else if predictionStatus.Prediction.Status == "failed" {
 			return "", fmt.Errorf("error: %s", predictionStatus.Prediction.Error)
 		} else {
-			time.Sleep(3 * time.Second)
+			select {
+			case <-time.After(settings.PollInterval):
+			case <-ctx.Done():
+				return "", fmt.Errorf("shutting down")
+			}
 		}
 		tries++
 	}
@@ -244,3 +230,11 @@ func callStableApi(input *Input) (string, error) {
 
 	return url, nil
 }
+
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Blobs keeps files under the data directory, named by the SHA-256 of their
+// content so the same file is only stored once.
+type Blobs struct {
+	dir string
+}
+
+// Blobs returns the files kept in the directory called name.
+func (s *Store) Blobs(name string) Blobs {
+	return Blobs{dir: filepath.Join(s.dir, name)}
+}
+
+// Put stores data, if it isn't stored already, and returns its hash.
+func (b Blobs) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(b.dir, hash)
+
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(b.dir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(b.dir, hash+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// Get reads the data stored under hash.
+func (b Blobs) Get(hash string) ([]byte, error) {
+	path, err := b.path(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Remove deletes the data stored under hash. It's not an error if there is
+// none.
+func (b Blobs) Remove(hash string) error {
+	path, err := b.path(hash)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (b Blobs) path(hash string) (string, error) {
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("bad hash %q", hash)
+	}
+	return filepath.Join(b.dir, hash), nil
+}
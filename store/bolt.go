@@ -0,0 +1,224 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// versions is the bucket recording which version each collection's items
+// were saved at.
+var versions = []byte("versions")
+
+// boltBucket keeps each item as JSON in a bucket named after the collection,
+// keyed by its position.
+type boltBucket[T any] struct {
+	db     *bolt.DB
+	name   []byte
+	legacy jsonFile[T]
+}
+
+// Load decodes the bucket's items. If they don't decode together, each is
+// tried on its own and the ones that still fail, or all of them if the saved
+// version can't be read, are moved to a <name>.corrupt-<time> bucket so the
+// rest can load.
+func (b boltBucket[T]) Load() ([]T, error) {
+	var keys, raw [][]byte
+	var savedVersion []byte
+	found := false
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.name)
+		if bucket == nil {
+			return nil
+		}
+		found = true
+
+		if saved := tx.Bucket(versions); saved != nil {
+			savedVersion = append([]byte(nil), saved.Get(b.name)...)
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			keys = append(keys, append([]byte(nil), k...))
+			raw = append(raw, append([]byte(nil), v...))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return b.importLegacy()
+	}
+
+	version := 1
+	if savedVersion != nil {
+		if version, err = strconv.Atoi(string(savedVersion)); err != nil {
+			return b.salvage(keys, raw, savedVersion, 0, fmt.Errorf("bad version for %s: %w", b.name, err))
+		}
+	}
+
+	items, err := decode[T](string(b.name), version, join(raw))
+	if errors.Is(err, ErrTooNew) {
+		return nil, err
+	}
+	if err != nil {
+		return b.salvage(keys, raw, savedVersion, version, err)
+	}
+
+	if version < Version(string(b.name)) {
+		if err := b.Save(items); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// salvage decodes items one at a time, quarantines the ones that fail and
+// saves the rest back at the current version. With version 0 they all fail.
+func (b boltBucket[T]) salvage(keys [][]byte, raw [][]byte, savedVersion []byte, version int, cause error) ([]T, error) {
+	var items []T
+	var bad []int
+	for n := range raw {
+		if version > 0 {
+			decoded, err := decode[T](string(b.name), version, join(raw[n:n+1]))
+			if err == nil && len(decoded) == 1 {
+				items = append(items, decoded...)
+				continue
+			}
+		}
+		bad = append(bad, n)
+	}
+
+	if len(bad) > 0 {
+		if err := b.quarantine(keys, raw, bad, savedVersion, cause); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.Save(items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// quarantine copies the items at bad, with the version they were saved at,
+// into a bucket of their own where they can be looked at later.
+func (b boltBucket[T]) quarantine(keys [][]byte, raw [][]byte, bad []int, savedVersion []byte, cause error) error {
+	stamp := fmt.Sprintf("%s.corrupt-%s", b.name, time.Now().Format("20060102T150405"))
+	var aside []byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		// Never add to an earlier quarantine, the keys could clash.
+		aside = []byte(stamp)
+		for n := 2; tx.Bucket(aside) != nil; n++ {
+			aside = []byte(fmt.Sprintf("%s-%d", stamp, n))
+		}
+
+		bucket, err := tx.CreateBucket(aside)
+		if err != nil {
+			return err
+		}
+		for _, n := range bad {
+			if err := bucket.Put(keys[n], raw[n]); err != nil {
+				return err
+			}
+		}
+
+		// Keep the version too, the items can't be read without it.
+		if savedVersion != nil {
+			saved, err := tx.CreateBucketIfNotExists(versions)
+			if err != nil {
+				return err
+			}
+			return saved.Put(aside, savedVersion)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not quarantine %s (%s): %w", b.name, cause, err)
+	}
+
+	log.Printf("could not decode %d of %d %s (%s), moved them to bucket %s", len(bad), len(raw), b.name, cause, aside)
+
+	return nil
+}
+
+// join makes a JSON list of raw items.
+func join(raw [][]byte) json.RawMessage {
+	return json.RawMessage(fmt.Sprintf("[%s]", bytes.Join(raw, []byte(","))))
+}
+
+// importLegacy copies the old JSON file into the database. Saving creates the
+// bucket, so this only happens once; the file itself is left alone.
+func (b boltBucket[T]) importLegacy() ([]T, error) {
+	items, err := b.legacy.Load()
+	if err != nil {
+		return nil, fmt.Errorf("could not import %s: %w", b.legacy.path, err)
+	}
+
+	if items == nil {
+		return nil, nil
+	}
+
+	if err := b.Save(items); err != nil {
+		return nil, err
+	}
+
+	log.Printf("imported %d %s from %s", len(items), b.name, b.legacy.path)
+
+	return items, nil
+}
+
+func (b boltBucket[T]) Save(items []T) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		saved, err := tx.CreateBucketIfNotExists(versions)
+		if err != nil {
+			return err
+		}
+
+		if v, err := strconv.Atoi(string(saved.Get(b.name))); err == nil {
+			if err := tooNew(string(b.name), v); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.DeleteBucket(b.name); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		bucket, err := tx.CreateBucket(b.name)
+		if err != nil {
+			return err
+		}
+
+		if err := saved.Put(b.name, []byte(strconv.Itoa(Version(string(b.name))))); err != nil {
+			return err
+		}
+
+		for index, item := range items {
+			value, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(index))
+
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// envelope is what a JSON file holds, so the items can be migrated when
+// their format changes.
+type envelope struct {
+	Version *int            `json:"version"`
+	Items   json.RawMessage `json:"items"`
+}
+
+type jsonFile[T any] struct {
+	name string
+	path string
+}
+
+// Load reads the file, falling back to the backup when the file is missing
+// or unparseable. Unparseable files are moved aside to <file>.corrupt-<time>
+// so they can be looked at later.
+func (f jsonFile[T]) Load() ([]T, error) {
+	items, err := f.loadFrom(f.path)
+	if err == nil {
+		return items, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		if errors.Is(err, ErrTooNew) || !f.quarantine(f.path, err) {
+			return nil, err
+		}
+	}
+
+	backup := f.path + ".bak"
+
+	items, err = f.loadFrom(backup)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		if errors.Is(err, ErrTooNew) || !f.quarantine(backup, err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	log.Printf("loaded %s from the backup %s", f.name, backup)
+
+	return items, nil
+}
+
+// errCorrupt marks errors that mean the file can't be parsed, as opposed to
+// it not being readable at all.
+type errCorrupt struct {
+	err error
+}
+
+func (e errCorrupt) Error() string {
+	return e.err.Error()
+}
+
+func (e errCorrupt) Unwrap() error {
+	return e.err
+}
+
+func (f jsonFile[T]) loadFrom(path string) ([]T, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	raw := json.RawMessage(file)
+
+	var env envelope
+	if bytes.HasPrefix(bytes.TrimSpace(file), []byte("{")) {
+		if err := json.Unmarshal(file, &env); err != nil {
+			return nil, errCorrupt{err}
+		}
+		if env.Version != nil {
+			version = *env.Version
+			raw = env.Items
+		}
+	}
+
+	items, err := decode[T](f.name, version, raw)
+	if err != nil && !errors.Is(err, ErrTooNew) {
+		return nil, errCorrupt{err}
+	}
+
+	return items, err
+}
+
+// quarantine moves a file that failed to load out of the way, reporting
+// whether it did.
+func (f jsonFile[T]) quarantine(path string, err error) bool {
+	var corrupt errCorrupt
+	if !errors.As(err, &corrupt) {
+		return false
+	}
+
+	aside := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102T150405"))
+	if err := os.Rename(path, aside); err != nil {
+		log.Printf("could not quarantine %s: %s", path, err)
+		return false
+	}
+
+	log.Printf("could not parse %s (%s), moved it to %s", path, corrupt.err, aside)
+
+	return true
+}
+
+// Save writes the items to a temporary file, syncs it and renames it over the
+// old file, which is kept as <file>.bak. A crash at any point leaves the old
+// or the new items behind, if only in the backup.
+func (f jsonFile[T]) Save(items []T) error {
+	if err := tooNew(f.name, savedVersion(f.path)); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	if items == nil {
+		items = []T{}
+	}
+
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	version := Version(f.name)
+	file, err := json.MarshalIndent(envelope{Version: &version, Items: encoded}, "", " ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(file); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.path, f.path+".bak"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// savedVersion is the version of the items in the file at path, or 0 when
+// there is no readable version to protect.
+func savedVersion(path string) int {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	var env envelope
+	if err := json.Unmarshal(file, &env); err != nil || env.Version == nil {
+		return 0
+	}
+	return *env.Version
+}
+
+// syncDir makes the renames in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
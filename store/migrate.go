@@ -0,0 +1,72 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrTooNew means the data was written by a newer version of the bot. It is
+// left untouched rather than quarantined: loading it fails, and so does
+// saving over it.
+var ErrTooNew = errors.New("data is newer than this version of the bot")
+
+// Migration upgrades the items of a collection, as raw JSON, from one version
+// to the next.
+type Migration func(items json.RawMessage) (json.RawMessage, error)
+
+// migrations holds, per collection, the migration from version i+1 at index
+// i. Data written before versioning, a bare JSON list, is version 1.
+var migrations = make(map[string][]Migration)
+
+// RegisterMigration adds the migration from version from of collection name
+// to the next version. Register them in order, from init functions.
+func RegisterMigration(name string, from int, m Migration) {
+	if from != Version(name) {
+		panic(fmt.Sprintf("migration for %s from version %d registered out of order", name, from))
+	}
+	migrations[name] = append(migrations[name], m)
+}
+
+// Version is the current version of collection name.
+func Version(name string) int {
+	return 1 + len(migrations[name])
+}
+
+// tooNew is the error for saving collection name over items saved at a
+// newer version, or nil if version isn't newer.
+func tooNew(name string, version int) error {
+	if current := Version(name); version > current {
+		return fmt.Errorf("%s were saved at version %d, I only know up to %d: %w", name, version, current, ErrTooNew)
+	}
+	return nil
+}
+
+// decode migrates items saved at version to the current version and
+// unmarshals them.
+func decode[T any](name string, version int, items json.RawMessage) ([]T, error) {
+	current := Version(name)
+
+	if err := tooNew(name, version); err != nil {
+		return nil, err
+	}
+
+	if version < 1 {
+		return nil, fmt.Errorf("%s have bad version %d", name, version)
+	}
+
+	for ; version < current; version++ {
+		var err error
+		items, err = migrations[name][version-1](items)
+		if err != nil {
+			return nil, fmt.Errorf("could not migrate %s from version %d: %w", name, version, err)
+		}
+	}
+
+	var decoded []T
+	if err := json.Unmarshal(items, &decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Repository loads and saves every item of one kind at once, which is how the
+// bot has always treated its data: a small slice kept in memory and written
+// back whenever it changes.
+type Repository[T any] interface {
+	Load() ([]T, error)
+	Save(items []T) error
+}
+
+const (
+	// Bolt keeps everything in one embedded database file, grumpy.db.
+	Bolt = "bolt"
+	// JSON keeps one JSON file per kind, <name>/<name>.json, the layout the
+	// bot used before there was a store.
+	JSON = "json"
+)
+
+// Store is an open data directory.
+type Store struct {
+	dir string
+	db  *bolt.DB
+}
+
+// Open prepares dir for the given backend, creating it if needed.
+func Open(dir string, backend string) (*Store, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	s := &Store{dir: dir}
+
+	switch backend {
+	case JSON:
+	case Bolt:
+		db, err := bolt.Open(filepath.Join(dir, "grumpy.db"), 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return nil, fmt.Errorf("could not open database: %w", err)
+		}
+		s.db = db
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", backend)
+	}
+
+	return s, nil
+}
+
+// DefaultDir is ~/.grumpy, where the bot has always kept its data.
+func DefaultDir() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homedir, ".grumpy"), nil
+}
+
+// Dir is the data directory the store was opened in.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Collection returns the repository holding items called name. With the bolt
+// backend, an existing <name>/<name>.json is imported the first time the
+// collection is loaded.
+func Collection[T any](s *Store, name string) Repository[T] {
+	file := jsonFile[T]{name: name, path: filepath.Join(s.dir, name, name+".json")}
+
+	if s.db == nil {
+		return file
+	}
+
+	return boltBucket[T]{db: s.db, name: []byte(name), legacy: file}
+}
@@ -0,0 +1,337 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type item struct {
+	Message   string
+	ChannelId string
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, backend := range []string{JSON, Bolt} {
+		s, err := Open(t.TempDir(), backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		repo := Collection[*item](s, "items")
+
+		items, err := repo.Load()
+		if err != nil || len(items) != 0 {
+			t.Fatalf("%s: empty load = %v, %v", backend, items, err)
+		}
+
+		want := []*item{{"hello", "1"}, {"bye", "2"}}
+		if err := repo.Save(want); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.Save(want[:1]); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want[:1]) {
+			t.Errorf("%s: loaded %v, want %v", backend, got, want[:1])
+		}
+	}
+}
+
+func TestBoltImportsJSONOnce(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "items"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := `[{"Message": "hello", "ChannelId": "1"}]`
+	if err := os.WriteFile(filepath.Join(dir, "items", "items.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(dir, Bolt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	repo := Collection[*item](s, "items")
+
+	items, err := repo.Load()
+	if err != nil || len(items) != 1 || items[0].Message != "hello" {
+		t.Fatalf("import = %v, %v", items, err)
+	}
+
+	if err := repo.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err = repo.Load()
+	if err != nil || len(items) != 0 {
+		t.Errorf("json file was imported again: %v, %v", items, err)
+	}
+}
+
+func TestJSONEnvelopeAndBackup(t *testing.T) {
+	s, err := Open(t.TempDir(), JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := Collection[*item](s, "items")
+
+	if err := repo.Save([]*item{{"first", "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save([]*item{{"second", "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(s.Dir(), "items", "items.json")
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(file), "{\n \"version\": 1,\n \"items\": [") {
+		t.Errorf("file is not wrapped in an envelope:\n%s", file)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 1, "items": [{"Mess`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := repo.Load()
+	if err != nil || len(items) != 1 || items[0].Message != "first" {
+		t.Fatalf("load with a corrupt file = %v, %v, want the backup", items, err)
+	}
+
+	quarantined, _ := filepath.Glob(path + ".corrupt-*")
+	if len(quarantined) != 1 {
+		t.Errorf("corrupt file was not quarantined: %v", quarantined)
+	}
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	RegisterMigration("renamed", 1, func(items json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(strings.ReplaceAll(string(items), `"Text"`, `"Message"`)), nil
+	})
+
+	for _, backend := range []string{JSON, Bolt} {
+		dir := t.TempDir()
+
+		if err := os.MkdirAll(filepath.Join(dir, "renamed"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		legacy := `[{"Text": "hello", "ChannelId": "1"}]`
+		if err := os.WriteFile(filepath.Join(dir, "renamed", "renamed.json"), []byte(legacy), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := Open(dir, backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		items, err := Collection[*item](s, "renamed").Load()
+		if err != nil || len(items) != 1 || items[0].Message != "hello" {
+			t.Errorf("%s: migrated load = %v, %v", backend, items, err)
+		}
+	}
+}
+
+func TestTooNewIsLeftAlone(t *testing.T) {
+	s, err := Open(t.TempDir(), JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(s.Dir(), "items", "items.json")
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": 99, "items": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Collection[*item](s, "items").Load(); !errors.Is(err, ErrTooNew) {
+		t.Errorf("load = %v, want ErrTooNew", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file was moved: %v", err)
+	}
+
+	if err := Collection[*item](s, "items").Save(nil); !errors.Is(err, ErrTooNew) {
+		t.Errorf("save = %v, want ErrTooNew", err)
+	}
+
+	if file, _ := os.ReadFile(path); !strings.Contains(string(file), "99") {
+		t.Errorf("saved over newer data: %s", file)
+	}
+}
+
+func TestBoltQuarantine(t *testing.T) {
+	s, err := Open(t.TempDir(), Bolt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	repo := Collection[*item](s, "items")
+	if err := repo.Save([]*item{{"hello", "1"}, {"broken", "2"}, {"bye", "3"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(key []byte, value []byte) {
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("items")).Put(key, value)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	corrupt([]byte{0, 0, 0, 0, 0, 0, 0, 1}, []byte(`{"Message": 42`))
+
+	items, err := repo.Load()
+	if err != nil || len(items) != 2 || items[0].Message != "hello" || items[1].Message != "bye" {
+		t.Fatalf("load = %v, %v", items, err)
+	}
+
+	quarantined := func() (n int) {
+		err := s.db.View(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+				if strings.HasPrefix(string(name), "items.corrupt-") {
+					n += bucket.Stats().KeyN
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := quarantined(); n != 1 {
+		t.Errorf("quarantined %d items, want the broken one", n)
+	}
+
+	// A version that can't be read quarantines everything.
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(versions).Put([]byte("items"), []byte("two"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items, err := repo.Load(); err != nil || len(items) != 0 {
+		t.Errorf("load with a bad version = %v, %v", items, err)
+	}
+	if n := quarantined(); n != 3 {
+		t.Errorf("quarantined %d items, want all 3", n)
+	}
+
+	if items, err := repo.Load(); err != nil || len(items) != 0 {
+		t.Errorf("load after quarantine = %v, %v", items, err)
+	}
+}
+
+func TestBoltTooNewIsLeftAlone(t *testing.T) {
+	s, err := Open(t.TempDir(), Bolt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	repo := Collection[*item](s, "items")
+	if err := repo.Save([]*item{{"hello", "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(versions).Put([]byte("items"), []byte("99"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Load(); !errors.Is(err, ErrTooNew) {
+		t.Errorf("load = %v, want ErrTooNew", err)
+	}
+
+	if err := repo.Save(nil); !errors.Is(err, ErrTooNew) {
+		t.Errorf("save = %v, want ErrTooNew", err)
+	}
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("items")).Stats().KeyN != 1 {
+			t.Error("saved over newer data")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlobs(t *testing.T) {
+	s, err := Open(t.TempDir(), JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := s.Blobs("attachments")
+
+	first, err := blobs.Put([]byte("cat picture"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := blobs.Put([]byte("cat picture"))
+	if err != nil || second != first {
+		t.Errorf("same content stored as %q and %q, %v", first, second, err)
+	}
+
+	stored, _ := filepath.Glob(filepath.Join(s.Dir(), "attachments", "*"))
+	if len(stored) != 1 {
+		t.Errorf("stored files = %v, want one", stored)
+	}
+
+	data, err := blobs.Get(first)
+	if err != nil || string(data) != "cat picture" {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+
+	if _, err := blobs.Get("../../etc/passwd"); err == nil {
+		t.Error("Get accepted a path instead of a hash")
+	}
+
+	if err := blobs.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobs.Get(first); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get after Remove = %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package timezone
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+)
+
+func init() {
+	command.Register(&command.Command{
+		Name:        "timezone",
+		Description: "your timezone for reminders",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "set",
+				Description: "set your timezone",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "zone",
+						Description: "IANA timezone, e.g. America/New_York",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "show",
+				Description: "show your timezone",
+			},
+		},
+		Handler: Timezone,
+	})
+}
@@ -0,0 +1,152 @@
+package timezone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"rawrippers.com/grumpy-daemon/command"
+	"rawrippers.com/grumpy-daemon/store"
+)
+
+// setting is how a user's timezone is saved.
+type setting struct {
+	UserId string
+	Zone   string
+}
+
+var (
+	mu    sync.Mutex
+	zones map[string]string
+	repo  store.Repository[*setting]
+)
+
+func init() {
+	store.RegisterMigration("timezones", 1, func(items json.RawMessage) (json.RawMessage, error) {
+		// Version 1 was a map from user id to zone, or already a list.
+		if bytes.HasPrefix(bytes.TrimSpace(items), []byte("[")) {
+			return items, nil
+		}
+
+		var old map[string]string
+		if err := json.Unmarshal(items, &old); err != nil {
+			return nil, err
+		}
+
+		saved := make([]*setting, 0, len(old))
+		for userId, zone := range old {
+			saved = append(saved, &setting{UserId: userId, Zone: zone})
+		}
+		sort.Slice(saved, func(i, j int) bool { return saved[i].UserId < saved[j].UserId })
+
+		return json.Marshal(saved)
+	})
+}
+
+func Load(st *store.Store) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loading := store.Collection[*setting](st, "timezones")
+
+	saved, err := loading.Load()
+	if err != nil {
+		return fmt.Errorf("could not load timezones: %w", err)
+	}
+	repo = loading
+
+	zones = make(map[string]string, len(saved))
+	for _, z := range saved {
+		zones[z.UserId] = z.Zone
+	}
+	log.Printf("loaded %d timezones", len(zones))
+
+	return nil
+}
+
+func Timezone(s *discordgo.Session, i *discordgo.InteractionCreate, args command.Args) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: timezone(args),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// Location returns the timezone a user picked with /timezone set, or the
+// server's local timezone if they haven't picked one.
+func Location(userId string) *time.Location {
+	mu.Lock()
+	name, ok := zones[userId]
+	mu.Unlock()
+
+	if !ok {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("bad timezone %q for %s: %s", name, userId, err)
+		return time.Local
+	}
+
+	return loc
+}
+
+func timezone(args command.Args) string {
+	switch args.Subcommand {
+	case "set":
+		return set(args.User.ID, args.String("zone"))
+	case "show":
+		return show(args.User.ID)
+	}
+
+	return "Use /timezone set or /timezone show."
+}
+
+func set(userId string, name string) string {
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" || name == "Local" {
+		return fmt.Sprintf("I don't know the timezone `%s`. Use an IANA name like `America/New_York` or `Europe/Berlin`.", name)
+	}
+
+	mu.Lock()
+	if zones == nil {
+		zones = make(map[string]string)
+	}
+	zones[userId] = loc.String()
+	write()
+	mu.Unlock()
+
+	return fmt.Sprintf("Your timezone is now `%s`, where it is %s.", loc, time.Now().In(loc).Format("15:04 Mon Jan 2"))
+}
+
+func show(userId string) string {
+	mu.Lock()
+	name, ok := zones[userId]
+	mu.Unlock()
+
+	if !ok {
+		return fmt.Sprintf("You haven't set a timezone, so I use `%s`. Use /timezone set to pick one.", time.Local)
+	}
+
+	return fmt.Sprintf("Your timezone is `%s`.", name)
+}
+
+func write() {
+	var saved []*setting
+	for userId, zone := range zones {
+		saved = append(saved, &setting{UserId: userId, Zone: zone})
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].UserId < saved[j].UserId })
+
+	if err := repo.Save(saved); err != nil {
+		log.Printf("could not save timezones: %s", err)
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxChoices is Discord's limit on autocomplete choices.
+	maxChoices = 25
+	// maxChoiceLength is Discord's limit on a choice's name and value.
+	maxChoiceLength = 100
+)
+
+// Choice is something an autocompleted option can be set to. Name is what
+// the user sees and Value is what the command gets.
+type Choice struct {
+	Name  string
+	Value string
+}
+
+// Fuzzy returns up to 25 of choices matching query, best first. A choice
+// matches if the letters of query appear in its name in order, and matches
+// better the closer they are to a prefix or an unbroken substring. Every
+// choice matches an empty query, in the order given. Choices with values too
+// long for Discord are left out.
+func Fuzzy(query string, choices []Choice) []*discordgo.ApplicationCommandOptionChoice {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	type scored struct {
+		choice Choice
+		score  int
+		order  int
+	}
+
+	var matched []scored
+	for order, choice := range choices {
+		if len(choice.Value) == 0 || len(choice.Value) > maxChoiceLength {
+			continue
+		}
+		if score, ok := fuzzyScore(query, strings.ToLower(choice.Name)); ok {
+			matched = append(matched, scored{choice, score, order})
+		}
+	}
+
+	sort.SliceStable(matched, func(a, b int) bool {
+		return matched[a].score > matched[b].score
+	})
+
+	if len(matched) > maxChoices {
+		matched = matched[:maxChoices]
+	}
+
+	result := make([]*discordgo.ApplicationCommandOptionChoice, len(matched))
+	for n, m := range matched {
+		result[n] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncateChoice(m.choice.Name),
+			Value: m.choice.Value,
+		}
+	}
+
+	return result
+}
+
+// fuzzyScore reports whether query's letters appear in name in order, and
+// scores how well: prefixes beat substrings, which beat scattered letters,
+// and fewer gaps beat more.
+func fuzzyScore(query string, name string) (int, bool) {
+	if len(query) == 0 {
+		return 0, true
+	}
+
+	if strings.HasPrefix(name, query) {
+		return 3000 - len(name), true
+	}
+
+	if strings.Contains(name, query) {
+		return 2000 - len(name), true
+	}
+
+	gaps := 0
+	at := 0
+	for index, r := range query {
+		found := strings.IndexRune(name[at:], r)
+		if found < 0 {
+			return 0, false
+		}
+		// at is just past the previous letter, so anything skipped is a gap.
+		if index > 0 && found > 0 {
+			gaps++
+		}
+		at += found + utf8.RuneLen(r)
+	}
+
+	return 1000 - 10*gaps - len(name), true
+}
+
+func truncateChoice(name string) string {
+	if len(name) == 0 {
+		return "(empty)"
+	}
+	if utf8.RuneCountInString(name) <= maxChoiceLength {
+		return name
+	}
+	return string([]rune(name)[:maxChoiceLength-1]) + "…"
+}
+
+// Suggest answers an autocomplete interaction with the choices matching
+// query, what the user has typed so far.
+func Suggest(s *discordgo.Session, i *discordgo.InteractionCreate, query string, choices []Choice) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: Fuzzy(query, choices),
+		},
+	})
+}
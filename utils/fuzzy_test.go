@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func names(choices []Choice, query string) []string {
+	var result []string
+	for _, c := range Fuzzy(query, choices) {
+		result = append(result, c.Name)
+	}
+	return result
+}
+
+func TestFuzzy(t *testing.T) {
+	choices := []Choice{
+		{"good morning", "1"},
+		{"morning", "2"},
+		{"go home", "3"},
+		{"standup", "4"},
+		{"Mornings are bad", "5"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"good morning", "morning", "go home", "standup", "Mornings are bad"}},
+		{"morn", []string{"morning", "Mornings are bad", "good morning"}},
+		{"gm", []string{"go home", "good morning"}},
+		{"STAND", []string{"standup"}},
+		{"xyz", nil},
+	}
+
+	for _, test := range tests {
+		got := names(choices, test.query)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("Fuzzy(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestFuzzyNonASCII(t *testing.T) {
+	// Each has one gap, and the shorter wins. Taking ö for one byte used to
+	// give öbxr a second gap between ö and b.
+	choices := []Choice{{"öxbrz", "1"}, {"öbxr", "2"}}
+
+	if got := names(choices, "öbr"); fmt.Sprint(got) != fmt.Sprint([]string{"öbxr", "öxbrz"}) {
+		t.Errorf("Fuzzy(%q) = %q", "öbr", got)
+	}
+}
+
+func TestFuzzyLimits(t *testing.T) {
+	var choices []Choice
+	for n := 0; n < 40; n++ {
+		choices = append(choices, Choice{fmt.Sprintf("reminder %d", n), fmt.Sprint(n)})
+	}
+	choices = append(choices, Choice{"too long", strings.Repeat("a", 101)})
+	choices = append(choices, Choice{strings.Repeat("é", 150), "long name"})
+
+	if got := Fuzzy("", choices); len(got) != maxChoices {
+		t.Errorf("got %d choices", len(got))
+	}
+
+	if got := Fuzzy("too long", choices); len(got) != 0 {
+		t.Errorf("choice with a long value was offered: %v", got[0])
+	}
+
+	got := Fuzzy("éé", choices)
+	if len(got) != 1 || len([]rune(got[0].Name)) != maxChoiceLength {
+		t.Errorf("long name came back as %+v", got)
+	}
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Trigger is something a Matcher looks for in messages.
+type Trigger[T any] struct {
+	// Key is where the trigger applies: the id of a channel, a category or
+	// a guild.
+	Key string
+	// Keyword is matched case-insensitively anywhere in a message, or only
+	// as whole words when WholeWord is set.
+	Keyword   string
+	WholeWord bool
+	// Pattern is used instead of Keyword for triggers that aren't a literal
+	// keyword. Patterns are tried one by one, so they are slower.
+	Pattern *regexp.Regexp
+	// Value is what Match returns when the trigger matches.
+	Value T
+}
+
+// Matcher finds the triggers matching a message. Triggers are indexed per
+// key into an Aho-Corasick automaton, which finds every keyword in one
+// pass over the message however many keywords there are. Indexes are only
+// rebuilt by Build and Update, when triggers change.
+type Matcher[T any] struct {
+	mu      sync.RWMutex
+	indexes map[string]*keyIndex[T]
+}
+
+type keyIndex[T any] struct {
+	triggers []Trigger[T]
+	keywords automaton
+	// byKeyword lists the triggers for each keyword in the automaton.
+	byKeyword [][]int
+	patterns  []int
+}
+
+func NewMatcher[T any]() *Matcher[T] {
+	return &Matcher[T]{indexes: make(map[string]*keyIndex[T])}
+}
+
+// Build replaces every trigger in m.
+func (m *Matcher[T]) Build(triggers []Trigger[T]) {
+	byKey := make(map[string][]Trigger[T])
+	for _, t := range triggers {
+		byKey[t.Key] = append(byKey[t.Key], t)
+	}
+
+	indexes := make(map[string]*keyIndex[T], len(byKey))
+	for key, t := range byKey {
+		indexes[key] = newKeyIndex(t)
+	}
+
+	m.mu.Lock()
+	m.indexes = indexes
+	m.mu.Unlock()
+}
+
+// Update replaces the triggers for one key, ignoring any triggers given for
+// other keys.
+func (m *Matcher[T]) Update(key string, triggers []Trigger[T]) {
+	var forKey []Trigger[T]
+	for _, t := range triggers {
+		if t.Key == key {
+			forKey = append(forKey, t)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(forKey) == 0 {
+		delete(m.indexes, key)
+		return
+	}
+	m.indexes[key] = newKeyIndex(forKey)
+}
+
+// Match returns the values of the triggers for key that match content, in
+// the order the triggers were given.
+func (m *Matcher[T]) Match(key string, content string) []T {
+	m.mu.RLock()
+	index, ok := m.indexes[key]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	return index.match(content)
+}
+
+func newKeyIndex[T any](triggers []Trigger[T]) *keyIndex[T] {
+	index := &keyIndex[T]{triggers: triggers}
+
+	ids := make(map[string]int)
+	var keywords []string
+
+	for i, t := range triggers {
+		if t.Pattern != nil {
+			index.patterns = append(index.patterns, i)
+			continue
+		}
+
+		keyword := strings.ToLower(t.Keyword)
+		if len(keyword) == 0 {
+			continue
+		}
+
+		id, ok := ids[keyword]
+		if !ok {
+			id = len(keywords)
+			ids[keyword] = id
+			keywords = append(keywords, keyword)
+			index.byKeyword = append(index.byKeyword, nil)
+		}
+		index.byKeyword[id] = append(index.byKeyword[id], i)
+	}
+
+	index.keywords = newAutomaton(keywords)
+
+	return index
+}
+
+func (index *keyIndex[T]) match(content string) []T {
+	matched := make(map[int]bool)
+
+	lower := strings.ToLower(content)
+	index.keywords.find(lower, func(keyword int, start int, end int) {
+		whole := isWholeWord(lower, start, end)
+		for _, i := range index.byKeyword[keyword] {
+			if whole || !index.triggers[i].WholeWord {
+				matched[i] = true
+			}
+		}
+	})
+
+	for _, i := range index.patterns {
+		if index.triggers[i].Pattern.MatchString(content) {
+			matched[i] = true
+		}
+	}
+
+	order := make([]int, 0, len(matched))
+	for i := range matched {
+		order = append(order, i)
+	}
+	sort.Ints(order)
+
+	values := make([]T, len(order))
+	for n, i := range order {
+		values[n] = index.triggers[i].Value
+	}
+
+	return values
+}
+
+// isWholeWord reports whether s[start:end] isn't part of a longer word. Only
+// edges that are themselves word characters need a boundary, so "c++" still
+// matches in "c++, really".
+func isWholeWord(s string, start int, end int) bool {
+	first, _ := utf8.DecodeRuneInString(s[start:end])
+	if IsWordRune(first) && start > 0 {
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		if IsWordRune(before) {
+			return false
+		}
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(s[start:end])
+	if IsWordRune(last) && end < len(s) {
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if IsWordRune(after) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsWordRune reports whether r is part of a word when matching whole words.
+func IsWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// automaton is an Aho-Corasick automaton over the bytes of its keywords.
+type automaton struct {
+	nodes   []acNode
+	lengths []int
+}
+
+type acNode struct {
+	next map[byte]int32
+	fail int32
+	// out lists the keywords ending at this node, including the ones
+	// reached through fail links.
+	out []int32
+}
+
+func newAutomaton(keywords []string) automaton {
+	a := automaton{nodes: []acNode{{}}, lengths: make([]int, len(keywords))}
+
+	for id, keyword := range keywords {
+		a.lengths[id] = len(keyword)
+
+		state := int32(0)
+		for i := 0; i < len(keyword); i++ {
+			next, ok := a.nodes[state].next[keyword[i]]
+			if !ok {
+				next = int32(len(a.nodes))
+				a.nodes = append(a.nodes, acNode{})
+				if a.nodes[state].next == nil {
+					a.nodes[state].next = make(map[byte]int32)
+				}
+				a.nodes[state].next[keyword[i]] = next
+			}
+			state = next
+		}
+		a.nodes[state].out = append(a.nodes[state].out, int32(id))
+	}
+
+	// Breadth first, so every fail link points at a finished node.
+	queue := make([]int32, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for b, child := range a.nodes[state].next {
+			fail := a.nodes[state].fail
+			for {
+				if next, ok := a.nodes[fail].next[b]; ok && next != child {
+					a.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+
+			a.nodes[child].out = append(a.nodes[child].out, a.nodes[a.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+
+	return a
+}
+
+// find calls found with the keyword id, start and end of every keyword in s,
+// overlapping ones included.
+func (a automaton) find(s string, found func(keyword int, start int, end int)) {
+	if len(a.lengths) == 0 {
+		return
+	}
+
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		for {
+			if next, ok := a.nodes[state].next[s[i]]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = a.nodes[state].fail
+		}
+
+		for _, id := range a.nodes[state].out {
+			found(int(id), i+1-a.lengths[id], i+1)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher[string]()
+	m.Build([]Trigger[string]{
+		{Key: "1", Keyword: "else", WholeWord: true, Value: "else"},
+		{Key: "1", Keyword: "thing", WholeWord: true, Value: "thing"},
+		{Key: "1", Keyword: "thing", Value: "thing anywhere"},
+		{Key: "1", Keyword: "Café", WholeWord: true, Value: "café"},
+		{Key: "1", Keyword: "c++", WholeWord: true, Value: "c++"},
+		{Key: "1", Pattern: regexp.MustCompile(`(?i)deploy(ed|ing)?\b`), Value: "deploy"},
+		{Key: "2", Keyword: "else", WholeWord: true, Value: "other channel"},
+	})
+
+	tests := map[string][]string{
+		"something ELSE":          {"else", "thing anywhere"},
+		"elsewhere":               nil,
+		"a thing":                 {"thing", "thing anywhere"},
+		"the café is open":        {"café"},
+		"cafés":                   nil,
+		"naïve café":              {"café"},
+		"écafé":                   nil,
+		"c++, really":             {"c++"},
+		"we're deploying, else?":  {"else", "deploy"},
+		"deployment of something": {"thing anywhere"},
+	}
+
+	for content, want := range tests {
+		if got := m.Match("1", content); !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+			t.Errorf("Match(%q) = %q, want %q", content, got, want)
+		}
+	}
+
+	m.Update("1", nil)
+	if got := m.Match("1", "something else"); len(got) > 0 {
+		t.Errorf("Match after clearing the channel = %q", got)
+	}
+	if got := m.Match("2", "something else"); !reflect.DeepEqual(got, []string{"other channel"}) {
+		t.Errorf("Update touched another channel: %q", got)
+	}
+}
+
+func TestAutomatonOverlaps(t *testing.T) {
+	a := newAutomaton([]string{"he", "she", "his", "hers"})
+
+	var found []string
+	a.find("ushers", func(keyword int, start int, end int) {
+		found = append(found, fmt.Sprintf("%d:%d-%d", keyword, start, end))
+	})
+
+	want := []string{"1:1-4", "0:2-4", "3:2-6"}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("find = %q, want %q", found, want)
+	}
+}
+
+func benchmarkTriggers(n int) ([]string, string) {
+	searches := make([]string, n)
+	for i := range searches {
+		searches[i] = fmt.Sprintf("keyword%d", i)
+	}
+
+	message := strings.Repeat("a perfectly ordinary message about nothing much ", 4) + searches[n/2]
+
+	return searches, message
+}
+
+func BenchmarkContainsSearch(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		searches, message := benchmarkTriggers(n)
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, search := range searches {
+					ContainsSearch(strings.ToLower(message), strings.ToLower(search))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		searches, message := benchmarkTriggers(n)
+
+		triggers := make([]Trigger[int], n)
+		for i, search := range searches {
+			triggers[i] = Trigger[int]{Key: "1", Keyword: search, WholeWord: true, Value: i}
+		}
+
+		m := NewMatcher[int]()
+		m.Build(triggers)
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.Match("1", message)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxPageLength keeps a page well inside an embed description's limit.
+	maxPageLength = 1800
+	maxPageLines  = 10
+	// maxJump is how many pages the jump select offers, Discord's limit on
+	// select options.
+	maxJump = 25
+)
+
+// List is a list command's reply, split into pages that are browsed with
+// buttons. The buttons' custom ids start with Prefix, followed by a colon
+// and the page they lead to, so the list can be rebuilt for any page without
+// keeping state.
+type List struct {
+	Prefix string
+	Title  string
+	// Empty is sent instead of an embed when there are no lines.
+	Empty string
+	Lines []string
+	// Code shows each page in a code block.
+	Code bool
+}
+
+// Page builds page n of l, counting from 0. Pages out of range are clamped.
+func (l List) Page(n int) *discordgo.InteractionResponseData {
+	if len(l.Lines) == 0 {
+		return &discordgo.InteractionResponseData{
+			Content:         l.Empty,
+			Embeds:          []*discordgo.MessageEmbed{},
+			Components:      []discordgo.MessageComponent{},
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		}
+	}
+
+	pages := l.pages()
+	if n >= len(pages) {
+		n = len(pages) - 1
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	description := strings.Join(pages[n], "\n")
+	if l.Code {
+		description = fmt.Sprintf("```%s```", description)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       l.Title,
+		Description: description,
+	}
+
+	data := &discordgo.InteractionResponseData{
+		Embeds:          []*discordgo.MessageEmbed{embed},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+		// Set even when empty, so a list that shrank to one page loses
+		// its buttons.
+		Components: []discordgo.MessageComponent{},
+	}
+
+	if len(pages) > 1 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d of %d", n+1, len(pages))}
+		data.Components = l.components(n, len(pages))
+	}
+
+	return data
+}
+
+// pages splits the lines into pages of at most maxPageLines lines and
+// maxPageLength characters.
+func (l List) pages() [][]string {
+	var pages [][]string
+	var page []string
+	length := 0
+
+	for _, line := range l.Lines {
+		if len(line) > maxPageLength {
+			line = strings.ToValidUTF8(line[:maxPageLength-len("…")], "") + "…"
+		}
+
+		if len(page) == maxPageLines || (len(page) > 0 && length+len(line)+1 > maxPageLength) {
+			pages = append(pages, page)
+			page = nil
+			length = 0
+		}
+
+		page = append(page, line)
+		length += len(line) + 1
+	}
+
+	return append(pages, page)
+}
+
+func (l List) components(n int, count int) []discordgo.MessageComponent {
+	// The custom ids of a message's components have to be unique, so the
+	// buttons say which one they are after the page.
+	buttons := discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Prev",
+				Style:    discordgo.SecondaryButton,
+				CustomID: fmt.Sprintf("%s:%d:prev", l.Prefix, n-1),
+				Disabled: n == 0,
+			},
+			discordgo.Button{
+				Label:    "Next",
+				Style:    discordgo.SecondaryButton,
+				CustomID: fmt.Sprintf("%s:%d:next", l.Prefix, n+1),
+				Disabled: n == count-1,
+			},
+		},
+	}
+
+	// Offer the pages around the current one when there are too many.
+	first := n - maxJump/2
+	if first > count-maxJump {
+		first = count - maxJump
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	var options []discordgo.SelectMenuOption
+	for page := first; page < count && page < first+maxJump; page++ {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:   fmt.Sprintf("Page %d", page+1),
+			Value:   strconv.Itoa(page),
+			Default: page == n,
+		})
+	}
+
+	jump := discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    l.Prefix + ":jump",
+				Placeholder: "Jump to page",
+				Options:     options,
+			},
+		},
+	}
+
+	return []discordgo.MessageComponent{buttons, jump}
+}
+
+// PageOf reads which page a list button or the jump select asks for.
+func PageOf(i *discordgo.InteractionCreate) int {
+	data := i.MessageComponentData()
+
+	_, rest, _ := strings.Cut(data.CustomID, ":")
+	page, _, _ := strings.Cut(rest, ":")
+
+	if page == "jump" {
+		if len(data.Values) == 0 {
+			return 0
+		}
+		page = data.Values[0]
+	}
+
+	n, err := strconv.Atoi(page)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// Turn answers a click on one of l's buttons by showing page n in place.
+func (l List) Turn(s *discordgo.Session, i *discordgo.InteractionCreate, n int) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: l.Page(n),
+	})
+}
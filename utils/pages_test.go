@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	return lines
+}
+
+func TestListPages(t *testing.T) {
+	tests := []struct {
+		lines []string
+		pages int
+	}{
+		{numbered(1), 1},
+		{numbered(10), 1},
+		{numbered(11), 2},
+		{numbered(95), 10},
+		{[]string{strings.Repeat("a", 1000), strings.Repeat("b", 1000)}, 2},
+		{[]string{strings.Repeat("é", 2000)}, 1},
+	}
+
+	for _, test := range tests {
+		pages := List{Lines: test.lines}.pages()
+		if len(pages) != test.pages {
+			t.Errorf("%d lines made %d pages, want %d", len(test.lines), len(pages), test.pages)
+		}
+		for _, page := range pages {
+			if length := len(strings.Join(page, "\n")); length > maxPageLength {
+				t.Errorf("page is %d long", length)
+			}
+		}
+	}
+}
+
+func TestListPage(t *testing.T) {
+	l := List{Prefix: "list", Title: "Things", Empty: "nothing", Lines: numbered(25), Code: true}
+
+	data := l.Page(99)
+	if footer := data.Embeds[0].Footer.Text; footer != "Page 3 of 3" {
+		t.Errorf("page past the end shows %q", footer)
+	}
+	if !strings.HasPrefix(data.Embeds[0].Description, "```line 20") {
+		t.Errorf("last page is %q", data.Embeds[0].Description)
+	}
+
+	buttons := data.Components[0].(discordgo.ActionsRow).Components
+	prev, next := buttons[0].(discordgo.Button), buttons[1].(discordgo.Button)
+	if prev.CustomID != "list:1:prev" || prev.Disabled {
+		t.Errorf("prev is %+v", prev)
+	}
+	if next.CustomID != "list:3:next" || !next.Disabled {
+		t.Errorf("next is %+v", next)
+	}
+
+	jump := data.Components[1].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if jump.CustomID != "list:jump" || len(jump.Options) != 3 || !jump.Options[2].Default {
+		t.Errorf("jump is %+v", jump)
+	}
+
+	if data := (List{Lines: numbered(3)}).Page(0); len(data.Components) != 0 || data.Embeds[0].Footer != nil {
+		t.Error("a single page has buttons")
+	}
+
+	if data := (List{Empty: "nothing"}).Page(0); data.Content != "nothing" || len(data.Embeds) != 0 {
+		t.Errorf("empty list is %+v", data)
+	}
+}
+
+func TestJumpOffersPagesAround(t *testing.T) {
+	l := List{Prefix: "list", Lines: numbered(1000)}
+
+	jump := l.Page(50).Components[1].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if len(jump.Options) != maxJump || jump.Options[0].Value != "38" {
+		t.Errorf("jump from page 50 starts at %s with %d options", jump.Options[0].Value, len(jump.Options))
+	}
+
+	jump = l.Page(99).Components[1].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if len(jump.Options) != maxJump || jump.Options[maxJump-1].Value != "99" {
+		t.Errorf("jump from the last page ends at %s", jump.Options[len(jump.Options)-1].Value)
+	}
+}
+
+func TestPageOf(t *testing.T) {
+	tests := []struct {
+		customId string
+		values   []string
+		page     int
+	}{
+		{"list:3:next", nil, 3},
+		{"list:0:prev", nil, 0},
+		{"list:jump", []string{"7"}, 7},
+		{"list:jump", nil, 0},
+		{"list:nonsense", nil, 0},
+	}
+
+	for _, test := range tests {
+		i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type: discordgo.InteractionMessageComponent,
+			Data: discordgo.MessageComponentInteractionData{CustomID: test.customId, Values: test.values},
+		}}
+		if page := PageOf(i); page != test.page {
+			t.Errorf("PageOf(%q, %q) = %d, want %d", test.customId, test.values, page, test.page)
+		}
+	}
+}
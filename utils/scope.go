@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Scope levels: a trigger fires in the channel it was set in, in every
+// channel of that channel's category, or everywhere in the guild.
+const (
+	ScopeChannel  = "channel"
+	ScopeCategory = "category"
+	ScopeGuild    = "guild"
+)
+
+// Scope is where a trigger fires. It is embedded in responses and reactions,
+// so its fields are saved alongside theirs.
+type Scope struct {
+	// ChannelId is the channel the trigger was set in.
+	ChannelId  string
+	GuildId    string `json:",omitempty"`
+	CategoryId string `json:",omitempty"`
+	// Level is one of the scope levels. Triggers saved before there were
+	// scopes have none and are channel triggers.
+	Level string `json:",omitempty"`
+	// OptOut lists channels where a category or guild trigger doesn't fire.
+	OptOut []string `json:",omitempty"`
+}
+
+// NewScope resolves level for a trigger being set in channelId.
+func NewScope(s *discordgo.Session, level string, guildId string, channelId string) (Scope, error) {
+	scope := Scope{ChannelId: channelId, GuildId: guildId, Level: level}
+
+	switch level {
+	case ScopeChannel, "":
+		scope.Level = ScopeChannel
+	case ScopeCategory:
+		scope.CategoryId = Category(s, channelId)
+		if len(scope.CategoryId) == 0 {
+			return scope, fmt.Errorf("this channel isn't in a category")
+		}
+	case ScopeGuild:
+		if len(guildId) == 0 {
+			return scope, fmt.Errorf("this isn't a server")
+		}
+	default:
+		return scope, fmt.Errorf("unknown scope %q", level)
+	}
+
+	return scope, nil
+}
+
+// Key is what the trigger is indexed by in a Matcher. The level is part of
+// it, since IDs of different levels can be the same: in older guilds the
+// default channel has the guild's ID.
+func (scope Scope) Key() string {
+	switch scope.Level {
+	case ScopeCategory:
+		return categoryKey(scope.CategoryId)
+	case ScopeGuild:
+		return guildKey(scope.GuildId)
+	}
+	return channelKey(scope.ChannelId)
+}
+
+func channelKey(id string) string  { return "c:" + id }
+func categoryKey(id string) string { return "k:" + id }
+func guildKey(id string) string    { return "g:" + id }
+
+// Name is the scope's level, for lists.
+func (scope Scope) Name() string {
+	if len(scope.Level) == 0 {
+		return ScopeChannel
+	}
+	return scope.Level
+}
+
+// AppliesIn reports whether the trigger covers a channel, whether or not the
+// channel opted out.
+func (scope Scope) AppliesIn(where Where) bool {
+	switch scope.Level {
+	case ScopeCategory:
+		return len(where.CategoryId) > 0 && scope.CategoryId == where.CategoryId
+	case ScopeGuild:
+		return len(where.GuildId) > 0 && scope.GuildId == where.GuildId
+	}
+	return scope.ChannelId == where.ChannelId
+}
+
+// OptedOut reports whether channelId opted out of the trigger.
+func (scope Scope) OptedOut(channelId string) bool {
+	for _, id := range scope.OptOut {
+		if id == channelId {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleOptOut opts channelId out of the trigger, or back in if it already
+// was, and reports whether it is now opted out.
+func (scope *Scope) ToggleOptOut(channelId string) bool {
+	for index, id := range scope.OptOut {
+		if id == channelId {
+			scope.OptOut = append(scope.OptOut[:index:index], scope.OptOut[index+1:]...)
+			return false
+		}
+	}
+
+	scope.OptOut = append(scope.OptOut, channelId)
+	return true
+}
+
+// Where is a channel along with the category and guild it is in.
+type Where struct {
+	ChannelId  string
+	CategoryId string
+	GuildId    string
+}
+
+// Locate finds the category of channelId. Channels outside a guild, like
+// DMs, have none.
+func Locate(s *discordgo.Session, guildId string, channelId string) Where {
+	where := Where{ChannelId: channelId, GuildId: guildId}
+	if len(guildId) > 0 {
+		where.CategoryId = Category(s, channelId)
+	}
+	return where
+}
+
+// Keys are the Matcher keys of every trigger that can fire in the channel.
+func (where Where) Keys() []string {
+	keys := []string{channelKey(where.ChannelId)}
+	if len(where.CategoryId) > 0 {
+		keys = append(keys, categoryKey(where.CategoryId))
+	}
+	if len(where.GuildId) > 0 {
+		keys = append(keys, guildKey(where.GuildId))
+	}
+	return keys
+}
+
+// Category returns the id of the category channelId is in, or "" if it
+// isn't in one. Threads count as being in their parent channel's category.
+func Category(s *discordgo.Session, channelId string) string {
+	channel := lookupChannel(s, channelId)
+	if channel == nil {
+		return ""
+	}
+
+	if channel.IsThread() {
+		channel = lookupChannel(s, channel.ParentID)
+		if channel == nil {
+			return ""
+		}
+	}
+
+	return channel.ParentID
+}
+
+func lookupChannel(s *discordgo.Session, channelId string) *discordgo.Channel {
+	if len(channelId) == 0 {
+		return nil
+	}
+
+	if channel, err := s.State.Channel(channelId); err == nil {
+		return channel
+	}
+
+	channel, err := s.Channel(channelId)
+	if err != nil {
+		return nil
+	}
+
+	return channel
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScopeAppliesIn(t *testing.T) {
+	here := Where{ChannelId: "1", CategoryId: "10", GuildId: "100"}
+	elsewhere := Where{ChannelId: "2", CategoryId: "20", GuildId: "100"}
+
+	tests := []struct {
+		scope     Scope
+		key       string
+		here      bool
+		elsewhere bool
+	}{
+		{Scope{ChannelId: "1"}, "c:1", true, false},
+		{Scope{ChannelId: "1", GuildId: "100", Level: ScopeChannel}, "c:1", true, false},
+		{Scope{ChannelId: "1", GuildId: "100", CategoryId: "10", Level: ScopeCategory}, "k:10", true, false},
+		{Scope{ChannelId: "1", GuildId: "100", Level: ScopeGuild}, "g:100", true, true},
+	}
+
+	for _, test := range tests {
+		if got := test.scope.Key(); got != test.key {
+			t.Errorf("%+v Key() = %q, want %q", test.scope, got, test.key)
+		}
+		if got := test.scope.AppliesIn(here); got != test.here {
+			t.Errorf("%+v AppliesIn(here) = %t", test.scope, got)
+		}
+		if got := test.scope.AppliesIn(elsewhere); got != test.elsewhere {
+			t.Errorf("%+v AppliesIn(elsewhere) = %t", test.scope, got)
+		}
+	}
+
+	if keys := here.Keys(); len(keys) != 3 {
+		t.Errorf("Keys() = %q", keys)
+	}
+	if keys := (Where{ChannelId: "1"}).Keys(); len(keys) != 1 {
+		t.Errorf("Keys() outside a guild = %q", keys)
+	}
+
+	// The default channel of an old guild has the guild's ID, and its
+	// channel and guild triggers must each be looked up once.
+	old := Where{ChannelId: "100", GuildId: "100"}
+	channel := Scope{ChannelId: "100", GuildId: "100", Level: ScopeChannel}
+	guild := Scope{ChannelId: "100", GuildId: "100", Level: ScopeGuild}
+	if channel.Key() == guild.Key() {
+		t.Errorf("channel and guild trigger share key %q", channel.Key())
+	}
+	if keys := old.Keys(); len(keys) != 2 || keys[0] != channel.Key() || keys[1] != guild.Key() {
+		t.Errorf("Keys() in the default channel = %q", keys)
+	}
+}
+
+func TestToggleOptOut(t *testing.T) {
+	scope := Scope{ChannelId: "1", GuildId: "100", Level: ScopeGuild}
+
+	if !scope.ToggleOptOut("2") || !scope.OptedOut("2") {
+		t.Error("channel didn't opt out")
+	}
+	if scope.OptedOut("3") {
+		t.Error("another channel opted out")
+	}
+	if scope.ToggleOptOut("2") || scope.OptedOut("2") {
+		t.Error("channel didn't opt back in")
+	}
+}
+
+func TestScopeReadsOldTriggers(t *testing.T) {
+	var old struct {
+		Search string
+		Scope
+	}
+
+	if err := json.Unmarshal([]byte(`{"Search": "hi", "ChannelId": "1"}`), &old); err != nil {
+		t.Fatal(err)
+	}
+
+	if old.ChannelId != "1" || old.Name() != ScopeChannel || old.Key() != "c:1" {
+		t.Errorf("old trigger came back as %+v", old)
+	}
+}